@@ -0,0 +1,95 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/alecthomas/kingpin"
+	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+var inputArchive = kingpin.Flag("input", "read the tree from a tar archive (.tar or .tar.gz) without extracting it; topDir and --root are paths inside the archive").String()
+
+// nil のときはディスクから読む
+var inputFS filesys.FileSystem
+
+func inputFileSystem() filesys.FileSystem {
+	if inputFS != nil {
+		return inputFS
+	}
+	return filesys.MakeFsOnDisk()
+}
+
+// アーカイブの中身をメモリ上のファイルシステムに展開し、topDir と --root をその中のパスに読み替える
+func useInputArchive(path string) error {
+	fs, err := loadArchive(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	inputFS = fs
+	onDisk = false
+
+	*topDir = filepath.Join(filesys.Separator, *topDir)
+	for i, root := range *roots {
+		(*roots)[i] = filepath.Join(filesys.Separator, root)
+	}
+	return nil
+}
+
+func loadArchive(path string) (filesys.FileSystem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// 拡張子ではなく先頭のマジックナンバーで gzip かどうかを見る
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	fs := filesys.MakeFsInMemory()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// "/" からの Join なので、"../" で始まるエントリもアーカイブの外には出ない
+		name := filepath.Join(filesys.Separator, filepath.FromSlash(hdr.Name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := fs.MkdirAll(name); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if err := fs.WriteFile(name, data); err != nil {
+				return nil, err
+			}
+		default:
+			zap.S().Debugf("%s: skipped unsupported tar entry type %q", hdr.Name, hdr.Typeflag)
+		}
+	}
+	return fs, nil
+}
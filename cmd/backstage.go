@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+var (
+	backstageOwner     = kingpin.Flag("backstage-owner", "default owner of Backstage entities (overridden by the 'backstage.io/owner' annotation)").Default("unknown").String()
+	backstageLifecycle = kingpin.Flag("backstage-lifecycle", "default lifecycle of Backstage entities (overridden by the 'backstage.io/lifecycle' annotation)").Default("production").String()
+	backstageSystem    = kingpin.Flag("backstage-system", "system the Backstage entities belong to").String()
+)
+
+const (
+	backstageOwnerAnnotation     = "backstage.io/owner"
+	backstageLifecycleAnnotation = "backstage.io/lifecycle"
+	backstagePathAnnotation      = "kustomize-graphing/path"
+)
+
+type BackstageEntity struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   BackstageMetadata `yaml:"metadata"`
+	Spec       BackstageSpec     `yaml:"spec"`
+}
+
+type BackstageMetadata struct {
+	Name        string            `yaml:"name"`
+	Title       string            `yaml:"title,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type BackstageSpec struct {
+	Type      string   `yaml:"type"`
+	Lifecycle string   `yaml:"lifecycle,omitempty"`
+	Owner     string   `yaml:"owner"`
+	System    string   `yaml:"system,omitempty"`
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+}
+
+var invalidBackstageNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+const maxBackstageNameLength = 63
+
+// Backstage の name に使えない文字を置き換えたもの。長さはまだ切り詰めない
+func backstageName(path string) string {
+	name := invalidBackstageNameChars.ReplaceAllString(strings.ReplaceAll(path, "/", "-"), "-")
+	name = strings.Trim(name, "-_.")
+	if name == "" {
+		name = "root"
+	}
+	return name
+}
+
+// 別のリポジトリにある同名・同バージョンのチャートを区別するため、リポジトリも名前に含める
+func helmChartBaseName(chart HelmChartNode) string {
+	name := "helm-"
+	if repo := chart.Repo; repo != "" {
+		if _, rest, ok := strings.Cut(repo, "://"); ok {
+			repo = rest
+		}
+		name += repo + "-"
+	}
+	return backstageName(name + chart.Name + "-" + chart.Version)
+}
+
+// Backstage の name は [a-z0-9A-Z-_.] の 63 文字までで、重複すると取り込めない。
+// 置き換えで衝突したものと切り詰めたものには、元のパス (チャートは ID) のハッシュを付ける
+func backstageEntityNames() map[string]string {
+	candidates := map[string][]string{}
+	for path := range nodes {
+		name := backstageName(path)
+		candidates[name] = append(candidates[name], path)
+	}
+	for _, chart := range helmCharts {
+		name := helmChartBaseName(chart)
+		candidates[name] = append(candidates[name], chart.ID())
+	}
+
+	names := map[string]string{}
+	for name, ids := range candidates {
+		for _, id := range ids {
+			if len(ids) == 1 && len(name) <= maxBackstageNameLength {
+				names[id] = name
+			} else {
+				names[id] = hashedBackstageName(name, id)
+			}
+		}
+	}
+	return names
+}
+
+func hashedBackstageName(name string, id string) string {
+	sum := sha256.Sum256([]byte(id))
+	suffix := "-" + hex.EncodeToString(sum[:])[:8]
+	if keep := maxBackstageNameLength - len(suffix); len(name) > keep {
+		// 末尾のほうがディレクトリを見分けやすいので先頭を落とす
+		name = strings.TrimLeft(name[len(name)-keep:], "-_.")
+	}
+	return name + suffix
+}
+
+func printBackstageEntities(w io.Writer) error {
+	names := backstageEntityNames()
+
+	dependsOn := map[string][]string{}
+	for _, edge := range edges {
+		switch edge.Type {
+		case EdgeTypeResource, EdgeTypeComponent:
+			if _, ok := nodes[edge.Dst]; !ok {
+				continue
+			}
+			dependsOn[edge.Src] = append(dependsOn[edge.Src], "component:"+names[edge.Dst])
+		case EdgeTypeHelmChart:
+			dependsOn[edge.Src] = append(dependsOn[edge.Src], "resource:"+names[edge.Dst])
+		}
+	}
+
+	var paths []string
+	for path := range nodes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+
+	for _, path := range paths {
+		node := nodes[path]
+
+		specType := "kustomization"
+		if node.Kind == types.ComponentKind {
+			specType = "kustomize-component"
+		}
+		entity := BackstageEntity{
+			APIVersion: "backstage.io/v1alpha1",
+			Kind:       "Component",
+			Metadata: BackstageMetadata{
+				Name:  names[path],
+				Title: path,
+				Annotations: map[string]string{
+					backstagePathAnnotation:                  path,
+					"kustomize-graphing/feature-fingerprint": node.Fingerprint,
+				},
+			},
+			Spec: BackstageSpec{
+				Type:      specType,
+				Lifecycle: annotationOr(node, backstageLifecycleAnnotation, *backstageLifecycle),
+				Owner:     annotationOr(node, backstageOwnerAnnotation, *backstageOwner),
+				System:    *backstageSystem,
+				DependsOn: dependsOn[path],
+			},
+		}
+		if err := enc.Encode(entity); err != nil {
+			return err
+		}
+	}
+
+	for _, chart := range helmCharts {
+		entity := BackstageEntity{
+			APIVersion: "backstage.io/v1alpha1",
+			Kind:       "Resource",
+			Metadata: BackstageMetadata{
+				Name:  names[chart.ID()],
+				Title: chart.Name,
+				Annotations: map[string]string{
+					"kustomize-graphing/helm-repo":    chart.Repo,
+					"kustomize-graphing/helm-version": chart.Version,
+				},
+			},
+			Spec: BackstageSpec{
+				Type:   "helm-chart",
+				Owner:  *backstageOwner,
+				System: *backstageSystem,
+			},
+		}
+		if err := enc.Encode(entity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func annotationOr(node *Node, key string, defaultValue string) string {
+	if v, ok := node.Annotations[key]; ok && v != "" {
+		return v
+	}
+	return defaultValue
+}
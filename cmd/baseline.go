@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var (
+	severityOverrides = kingpin.Flag("severity-overrides", "change the severity of findings per field or lint rule, as 'name=error|warning|info' (comma-separated or repeatable, e.g. 'transformers=info' or 'unused-file=info'); a field takes precedence over a rule").Strings()
+	baselineFile      = kingpin.Flag("baseline", "findings file (as written by --findings-output) listing known issues; they are reported as suppressed and do not fail --strict or lint").ExistingFile()
+)
+
+type baselineKey struct {
+	Kustomization string
+	Field         string
+	Path          string
+	Rule          string
+}
+
+var (
+	overrideSeverities = map[string]Severity{} // フィールド名または lint のルール名ごと
+	baselineKeys       = map[baselineKey]bool{}
+)
+
+func parseSeverityOverrides(overrides []string) error {
+	for _, override := range overrides {
+		for _, entry := range strings.Split(override, ",") {
+			field, severity, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			switch s := Severity(strings.TrimSpace(severity)); {
+			case !ok:
+				return fmt.Errorf("invalid severity override %q (expected 'name=severity')", entry)
+			case s != SeverityError && s != SeverityWarning && s != SeverityInfo:
+				return fmt.Errorf("invalid severity %q in %q (expected 'error', 'warning' or 'info')", severity, entry)
+			default:
+				overrideSeverities[strings.TrimSpace(field)] = s
+			}
+		}
+	}
+	return nil
+}
+
+// ビルドエラーのメッセージには絶対パスが入るので、メッセージは照合に使わない。
+// rule のない (古い --findings-output の) ものは、ルールを問わずに照合する
+func loadBaseline(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var findings []Finding
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, f := range findings {
+		baselineKeys[baselineKey{f.Kustomization, f.Field, f.Path, f.Rule}] = true
+	}
+	return nil
+}
+
+func applyFindingPolicy(f *Finding) {
+	if s, ok := overrideSeverities[f.Field]; ok && f.Field != "" {
+		f.Severity = s
+	} else if s, ok := overrideSeverities[f.Rule]; ok && f.Rule != "" {
+		f.Severity = s
+	}
+	f.Suppressed = baselineKeys[baselineKey{f.Kustomization, f.Field, f.Path, f.Rule}] ||
+		baselineKeys[baselineKey{f.Kustomization, f.Field, f.Path, ""}]
+}
+
+// --strict で失敗とするもの
+func countFailingFindings(findings []Finding) int {
+	n := 0
+	for _, f := range findings {
+		if f.Severity == SeverityError && !f.Suppressed {
+			n++
+		}
+	}
+	return n
+}
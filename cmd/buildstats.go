@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var buildStats = kingpin.Flag("build-stats", "run 'kustomize build' on every kustomization and annotate nodes with the number of rendered resources and build errors (slow)").Bool()
+
+const buildFailedNodeAttrs = `fillcolor=mistyrose, fontcolor=red`
+
+type BuildResult = graph.BuildResult
+
+// ラベルの末尾に付け足す行。--build-stats がなければ空
+func buildStatsLabel(path string) string {
+	node := nodes[path]
+	if node == nil || node.Build == nil {
+		return ""
+	}
+	if node.Build.Error != "" {
+		return "\nbuild failed"
+	}
+	if node.Build.Resources == 1 {
+		return "\n1 resource"
+	}
+	return fmt.Sprintf("\n%d resources", node.Build.Resources)
+}
+
+func buildStatsAttrs(path string) string {
+	node := nodes[path]
+	if node == nil || node.Build == nil || node.Build.Error == "" {
+		return ""
+	}
+	if hasNodeTooltip(path) {
+		// --tooltips のツールチップにエラーも含まれる
+		return ", " + buildFailedNodeAttrs
+	}
+	return ", " + buildFailedNodeAttrs + fmt.Sprintf(`, tooltip="%s"`, escapeDOT(node.Build.Error, `\n`))
+}
+
+func countBuildFailures() int {
+	n := 0
+	for _, node := range nodes {
+		if node.Build != nil && node.Build.Error != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var (
+	checkDirCmd         = kingpin.Command("check-dir", "validate only the direct references of one kustomization (fast; for editor hooks)")
+	checkDirPath        = checkDirCmd.Arg("dir", "directory containing kustomization.yaml").Required().String()
+	checkLoadRestrictor = checkDirCmd.Flag("load-restrictor", "kustomize load restrictor to validate file references against").Default("LoadRestrictionsRootOnly").Enum("LoadRestrictionsRootOnly", "LoadRestrictionsNone")
+)
+
+type CheckIssue struct {
+	Rule    LintRule
+	Field   string
+	Ref     string
+	Message string
+	Line    int
+}
+
+// ディレクトリ全体は走査せず、1 つの kustomization の直接の参照だけを調べる
+func checkDir(fs filesys.FileSystem, dir string, loadRestrictor string) ([]CheckIssue, error) {
+	k, data, err := graph.ReadKustomizationFile(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	k.FixKustomization()
+
+	var issues []CheckIssue
+	seen := map[string]bool{}
+	add := func(rule LintRule, field string, ref string, message string) {
+		issues = append(issues, CheckIssue{Rule: rule, Field: field, Ref: ref, Message: message, Line: findingLine(data, field, ref)})
+	}
+	occurrences := map[string]int{}
+	check := func(field string, ref string, dirAllowed bool) {
+		if ref == "" {
+			return // インラインの patch や replacement
+		}
+		key := field + "\x00" + ref
+		occurrences[key]++
+		if seen[key] {
+			add(LintRuleDuplicateReference, field, ref, "duplicated")
+			// 2 回目以降の出現の行を指す
+			if lines := findingLines(data, field, ref); len(lines) >= occurrences[key] {
+				issues[len(issues)-1].Line = lines[occurrences[key]-1]
+			}
+			return
+		}
+		seen[key] = true
+
+		path := filepath.Join(dir, ref)
+		if !fs.Exists(path) {
+			if !dirAllowed || !graph.IsRemoteReference(ref) {
+				add(LintRuleMissingReference, field, ref, "not found")
+			}
+			return
+		}
+
+		isDir := fs.IsDir(path)
+		if isDir && !dirAllowed {
+			add(LintRuleInvalidReference, field, ref, "is a directory")
+			return
+		}
+		// ディレクトリ (base) は root の外でもよいが、ファイルは root 以下に限られる
+		if !isDir && loadRestrictor == "LoadRestrictionsRootOnly" && !isWithinDir(dir, path) {
+			add(LintRuleLoadRestrictor, field, ref, "is outside the kustomization root (LoadRestrictionsRootOnly)")
+		}
+	}
+
+	for _, v := range k.Resources {
+		check("resources", v, true)
+	}
+	for _, v := range k.Components {
+		check("components", v, true)
+	}
+	for _, v := range k.ConfigMapGenerator {
+		checkGeneratorSources("configMapGenerator", v.GeneratorArgs, check)
+	}
+	for _, v := range k.SecretGenerator {
+		checkGeneratorSources("secretGenerator", v.GeneratorArgs, check)
+	}
+	for _, v := range k.Patches {
+		check("patches", v.Path, false)
+	}
+	for _, v := range k.Replacements {
+		check("replacements", v.Path, false)
+	}
+	for _, v := range k.Transformers {
+		check("transformers", v, true)
+	}
+	for _, v := range k.Configurations {
+		check("configurations", v, false)
+	}
+
+	return issues, nil
+}
+
+func checkGeneratorSources(field string, args types.GeneratorArgs, check func(field string, ref string, dirAllowed bool)) {
+	for _, v := range args.FileSources {
+		// files は "[key=]path" 形式
+		if i := strings.Index(v, "="); i >= 0 {
+			v = v[i+1:]
+		}
+		check(field, v, false)
+	}
+	for _, v := range args.EnvSources {
+		check(field, v, false)
+	}
+}
+
+func isWithinDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func runCheckDir() error {
+	dir := *checkDirPath
+	issues, err := checkDir(filesys.MakeFsOnDisk(), dir, *checkLoadRestrictor)
+	if err != nil {
+		return err
+	}
+
+	// エディタがジャンプできるように file:line: message 形式で出す
+	file := filepath.Join(dir, "kustomization.yaml")
+	err = writeOutput(func(w io.Writer) error {
+		for _, issue := range issues {
+			fmt.Fprintf(w, "%s:%d: %s: %s %s\n", file, issue.Line, issue.Field, issue.Ref, issue.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("%d problems found in %s", len(issues), file)
+	}
+	return nil
+}
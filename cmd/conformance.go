@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var conformanceFiles = conformanceCmd.Flag("file", "also check an externally rendered file against the model, as 'format=path' (format: dot, graphml, cytoscape, csv, nodes-csv, backstage, plantuml or d2; repeatable)").Strings()
+
+// 出力形式から読み戻したグラフの構造。nil のものは比較しない
+type graphShape struct {
+	Nodes map[string]bool
+	Edges map[[2]string]bool
+}
+
+type conformanceFormat struct {
+	print func(w io.Writer) error
+	parse func(data []byte) (graphShape, error)
+}
+
+// tree は人が読むためのものなので対象外
+var conformanceFormats = map[string]conformanceFormat{
+	"dot":       {printGraph, parseDOTShape},
+	"graphml":   {printGraphML, parseGraphMLShape},
+	"cytoscape": {printCytoscapeJSON, parseCytoscapeShape},
+	"csv":       {printEdgesCSV, parseEdgesCSVShape},
+	"nodes-csv": {printNodesCSV, parseNodesCSVShape},
+	"backstage": {printBackstageEntities, parseBackstageShape},
+	"plantuml":  {printPlantUML, parsePlantUMLShape},
+	"d2":        {printD2, parseD2Shape},
+}
+
+func modelShape() graphShape {
+	shape := graphShape{Nodes: map[string]bool{}, Edges: map[[2]string]bool{}}
+	for _, node := range flatNodes() {
+		shape.Nodes[node.ID] = true
+	}
+	for _, edge := range edges {
+		shape.Edges[[2]string{edge.Src, edge.Dst}] = true
+	}
+	return shape
+}
+
+// Backstage には kustomization と helm チャートだけが entity として出る
+func backstageModelShape() graphShape {
+	shape := graphShape{Nodes: map[string]bool{}, Edges: map[[2]string]bool{}}
+	names := backstageEntityNames()
+	for path := range nodes {
+		shape.Nodes[names[path]] = true
+	}
+	for _, chart := range helmCharts {
+		shape.Nodes[names[chart.ID()]] = true
+	}
+	for _, edge := range edges {
+		switch {
+		case isKustomizationEdge(edge) && nodes[edge.Dst] != nil:
+			shape.Edges[[2]string{names[edge.Src], names[edge.Dst]}] = true
+		case edge.Type == EdgeTypeHelmChart:
+			shape.Edges[[2]string{names[edge.Src], names[edge.Dst]}] = true
+		}
+	}
+	return shape
+}
+
+// PlantUML と D2 はノードをパスではなく別名で書くので、クラスタのパスとラベルの組で比べる。
+// 同じクラスタにある同じラベルのノード (同名の helm チャートなど) は区別できない
+func displayModelShape(escape func(string) string) graphShape {
+	keys := map[string]string{}
+	var walk func(t *ClusterTree, prefix string)
+	walk = func(t *ClusterTree, prefix string) {
+		for _, node := range t.Nodes {
+			keys[node.ID] = prefix + escape(node.Label)
+		}
+		for _, name := range t.ChildNames() {
+			walk(t.Children[name], prefix+escape(name)+"/")
+		}
+	}
+	walk(clusterTree(flatNodes()), "")
+
+	shape := graphShape{Nodes: map[string]bool{}, Edges: map[[2]string]bool{}}
+	for _, key := range keys {
+		shape.Nodes[key] = true
+	}
+	for _, edge := range edges {
+		shape.Edges[[2]string{keys[edge.Src], keys[edge.Dst]}] = true
+	}
+	return shape
+}
+
+var (
+	dotQuoted   = `"((?:[^"\\]|\\.)*)"`
+	dotEdgeLine = regexp.MustCompile(`^\s*` + dotQuoted + `\s*->\s*` + dotQuoted)
+	dotNodeLine = regexp.MustCompile(`^\s*` + dotQuoted + `\s*(\[|$)`)
+)
+
+// printGraph は 1 行に 1 文なので、行単位で読めば足りる
+func parseDOTShape(data []byte) (graphShape, error) {
+	shape := graphShape{Nodes: map[string]bool{}, Edges: map[[2]string]bool{}}
+	unquote := strings.NewReplacer(`\"`, `"`, `\\`, `\`)
+	for _, line := range strings.Split(string(data), "\n") {
+		if m := dotEdgeLine.FindStringSubmatch(line); m != nil {
+			src, dst := unquote.Replace(m[1]), unquote.Replace(m[2])
+			shape.Edges[[2]string{src, dst}] = true
+			shape.Nodes[src], shape.Nodes[dst] = true, true
+		} else if m := dotNodeLine.FindStringSubmatch(line); m != nil {
+			shape.Nodes[unquote.Replace(m[1])] = true
+		}
+	}
+	return shape, nil
+}
+
+func parseGraphMLShape(data []byte) (graphShape, error) {
+	var doc graphML
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return graphShape{}, err
+	}
+	shape := graphShape{Nodes: map[string]bool{}, Edges: map[[2]string]bool{}}
+	for _, node := range doc.Graph.Nodes {
+		shape.Nodes[node.ID] = true
+	}
+	for _, edge := range doc.Graph.Edges {
+		shape.Edges[[2]string{edge.Source, edge.Target}] = true
+	}
+	return shape, nil
+}
+
+func parseCytoscapeShape(data []byte) (graphShape, error) {
+	var doc struct {
+		Elements cytoscapeElements `json:"elements"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return graphShape{}, err
+	}
+	shape := graphShape{Nodes: map[string]bool{}, Edges: map[[2]string]bool{}}
+	for _, node := range doc.Elements.Nodes {
+		shape.Nodes[node.Data["id"]] = true
+	}
+	for _, edge := range doc.Elements.Edges {
+		shape.Edges[[2]string{edge.Data["source"], edge.Data["target"]}] = true
+	}
+	return shape, nil
+}
+
+func readCSVRecords(data []byte) ([][]string, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("missing header")
+	}
+	return records[1:], nil
+}
+
+// エッジの CSV には孤立したノードが出ないので、ノードは比較しない
+func parseEdgesCSVShape(data []byte) (graphShape, error) {
+	records, err := readCSVRecords(data)
+	if err != nil {
+		return graphShape{}, err
+	}
+	shape := graphShape{Edges: map[[2]string]bool{}}
+	for _, record := range records {
+		if len(record) < 2 {
+			return graphShape{}, fmt.Errorf("invalid record %q", record)
+		}
+		shape.Edges[[2]string{record[0], record[1]}] = true
+	}
+	return shape, nil
+}
+
+func parseNodesCSVShape(data []byte) (graphShape, error) {
+	records, err := readCSVRecords(data)
+	if err != nil {
+		return graphShape{}, err
+	}
+	shape := graphShape{Nodes: map[string]bool{}}
+	for _, record := range records {
+		shape.Nodes[record[0]] = true
+	}
+	return shape, nil
+}
+
+func parseBackstageShape(data []byte) (graphShape, error) {
+	shape := graphShape{Nodes: map[string]bool{}, Edges: map[[2]string]bool{}}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var entity BackstageEntity
+		if err := dec.Decode(&entity); err == io.EOF {
+			break
+		} else if err != nil {
+			return graphShape{}, err
+		}
+		shape.Nodes[entity.Metadata.Name] = true
+		for _, ref := range entity.Spec.DependsOn {
+			if _, name, ok := strings.Cut(ref, ":"); ok {
+				ref = name
+			}
+			shape.Edges[[2]string{entity.Metadata.Name, ref}] = true
+		}
+	}
+	return shape, nil
+}
+
+var (
+	plantUMLElementLine = regexp.MustCompile(`^\s*\w+ "([^"]*)" as (\w+)$`)
+	plantUMLPackageLine = regexp.MustCompile(`^\s*package "([^"]*)" \{$`)
+	plantUMLEdgeLine    = regexp.MustCompile(`^\s*(\w+) (?:-->|\.\.>) (\w+)$`)
+)
+
+func parsePlantUMLShape(data []byte) (graphShape, error) {
+	shape := graphShape{Nodes: map[string]bool{}, Edges: map[[2]string]bool{}}
+	aliases := map[string]string{}
+	var packages []string
+	for _, line := range strings.Split(string(data), "\n") {
+		prefix := strings.Join(append(packages, ""), "/")
+		switch m := plantUMLElementLine.FindStringSubmatch(line); {
+		case m != nil:
+			aliases[m[2]] = prefix + m[1]
+			shape.Nodes[prefix+m[1]] = true
+		case plantUMLPackageLine.MatchString(line):
+			packages = append(packages, plantUMLPackageLine.FindStringSubmatch(line)[1])
+		case strings.TrimSpace(line) == "}":
+			if len(packages) == 0 {
+				return graphShape{}, errors.New("unbalanced '}'")
+			}
+			packages = packages[:len(packages)-1]
+		case plantUMLEdgeLine.MatchString(line):
+			m := plantUMLEdgeLine.FindStringSubmatch(line)
+			src, ok1 := aliases[m[1]]
+			dst, ok2 := aliases[m[2]]
+			if !ok1 || !ok2 {
+				return graphShape{}, fmt.Errorf("edge %q refers to an undefined element", strings.TrimSpace(line))
+			}
+			shape.Edges[[2]string{src, dst}] = true
+		}
+	}
+	return shape, nil
+}
+
+var (
+	d2Quoted        = `"((?:[^"\\]|\\.)*)"`
+	d2NodeLine      = regexp.MustCompile(`^\s*(\w+): ` + d2Quoted + ` \{shape: \w+\}$`)
+	d2ContainerLine = regexp.MustCompile(`^\s*(\w+): ` + d2Quoted + ` \{$`)
+	d2EdgeLine      = regexp.MustCompile(`^\s*([\w.]+) -> ([\w.]+)(?:: .*)?$`)
+)
+
+func parseD2Shape(data []byte) (graphShape, error) {
+	shape := graphShape{Nodes: map[string]bool{}, Edges: map[[2]string]bool{}}
+	unquote := strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`)
+	labels := map[string]string{} // "c0.n1" のようなキー → クラスタのパスとラベル
+	type container struct{ key, label string }
+	var containers []container
+	prefixes := func() (string, string) {
+		key, label := "", ""
+		for _, c := range containers {
+			key, label = key+c.key+".", label+c.label+"/"
+		}
+		return key, label
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		keyPrefix, labelPrefix := prefixes()
+		if m := d2NodeLine.FindStringSubmatch(line); m != nil {
+			labels[keyPrefix+m[1]] = labelPrefix + unquote.Replace(m[2])
+			shape.Nodes[labelPrefix+unquote.Replace(m[2])] = true
+		} else if m := d2ContainerLine.FindStringSubmatch(line); m != nil {
+			containers = append(containers, container{key: m[1], label: unquote.Replace(m[2])})
+		} else if strings.TrimSpace(line) == "}" {
+			if len(containers) == 0 {
+				return graphShape{}, errors.New("unbalanced '}'")
+			}
+			containers = containers[:len(containers)-1]
+		} else if m := d2EdgeLine.FindStringSubmatch(line); m != nil {
+			src, ok1 := labels[m[1]]
+			dst, ok2 := labels[m[2]]
+			if !ok1 || !ok2 {
+				return graphShape{}, fmt.Errorf("edge %q refers to an undefined shape", strings.TrimSpace(line))
+			}
+			shape.Edges[[2]string{src, dst}] = true
+		}
+	}
+	return shape, nil
+}
+
+// 期待する構造との差分。一致していれば空
+func diffShapes(expected graphShape, actual graphShape) []string {
+	var diffs []string
+	if actual.Nodes != nil {
+		for id := range expected.Nodes {
+			if !actual.Nodes[id] {
+				diffs = append(diffs, "missing node "+id)
+			}
+		}
+		for id := range actual.Nodes {
+			if !expected.Nodes[id] {
+				diffs = append(diffs, "unexpected node "+id)
+			}
+		}
+	}
+	if actual.Edges != nil {
+		for e := range expected.Edges {
+			if !actual.Edges[e] {
+				diffs = append(diffs, fmt.Sprintf("missing edge %s -> %s", e[0], e[1]))
+			}
+		}
+		for e := range actual.Edges {
+			if !expected.Edges[e] {
+				diffs = append(diffs, fmt.Sprintf("unexpected edge %s -> %s", e[0], e[1]))
+			}
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+func checkConformance(name string, format conformanceFormat, data []byte) (graphShape, []string, error) {
+	actual, err := format.parse(data)
+	if err != nil {
+		return graphShape{}, nil, err
+	}
+	expected := modelShape()
+	switch name {
+	case "backstage":
+		expected = backstageModelShape()
+	case "plantuml":
+		expected = displayModelShape(escapePlantUML)
+	case "d2":
+		expected = displayModelShape(func(s string) string { return s })
+	}
+	return actual, diffShapes(expected, actual), nil
+}
+
+func printConformance(w io.Writer) error {
+	// 注釈や折りたたみはノードの集合を変えるので、素の出力で比べる
+	*collapseDeemphasized = false
+	notes = nil
+
+	type target struct {
+		name   string
+		source string
+		data   []byte
+	}
+	var targets []target
+
+	var names []string
+	for name := range conformanceFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var buf bytes.Buffer
+		if err := conformanceFormats[name].print(&buf); err != nil {
+			return fmt.Errorf("failed to render %s: %w", name, err)
+		}
+		targets = append(targets, target{name: name, source: name, data: buf.Bytes()})
+	}
+	for _, file := range *conformanceFiles {
+		name, path, ok := strings.Cut(file, "=")
+		if !ok {
+			return fmt.Errorf("invalid --file %q (expected 'format=path')", file)
+		}
+		if _, ok := conformanceFormats[name]; !ok {
+			return fmt.Errorf("unknown format %q in --file %q", name, file)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target{name: name, source: path, data: data})
+	}
+
+	failed := 0
+	for _, t := range targets {
+		shape, diffs, err := checkConformance(t.name, conformanceFormats[t.name], t.data)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(w, "FAIL %s: failed to parse: %v\n", t.source, err)
+		case len(diffs) > 0:
+			failed++
+			fmt.Fprintf(w, "FAIL %s\n", t.source)
+			for _, diff := range diffs {
+				fmt.Fprintf(w, "  %s\n", diff)
+			}
+		default:
+			var counts []string
+			if shape.Nodes != nil {
+				counts = append(counts, fmt.Sprintf("%d nodes", len(shape.Nodes)))
+			}
+			if shape.Edges != nil {
+				counts = append(counts, fmt.Sprintf("%d edges", len(shape.Edges)))
+			}
+			fmt.Fprintf(w, "ok   %s (%s)\n", t.source, strings.Join(counts, ", "))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d outputs do not match the model", failed, len(targets))
+	}
+	return nil
+}
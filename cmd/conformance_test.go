@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/alecthomas/kingpin"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// helm チャート、Component、詳細モードのファイル、記号を含むディレクトリ名をひととおり含むツリー。
+// メモリ上のファイルシステムは空白や引用符を名前に使えないので、d2 や PlantUML で区切りになる ':' と '.' を使う
+var conformanceFixture = map[string]string{
+	"base/kustomization.yaml": `resources:
+- deploy.yaml
+configMapGenerator:
+- name: app
+  files:
+  - app.conf
+  - key=extra.conf
+secretGenerator:
+- name: app
+  envs:
+  - missing.env
+replacements:
+- path: replacement.yaml
+- source:
+    kind: ConfigMap
+    name: app
+    fieldPath: data.NAME
+  targets:
+  - select:
+      kind: Deployment
+    fieldPaths:
+    - metadata.name
+`,
+	"base/deploy.yaml":      "kind: Deployment\n",
+	"base/app.conf":         "a=b\n",
+	"base/extra.conf":       "c=d\n",
+	"base/replacement.yaml": "source: {}\n",
+
+	"components/monitoring/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1alpha1
+kind: Component
+configMapGenerator:
+- name: monitoring
+  files:
+  - rules.yaml
+`,
+	"components/monitoring/rules.yaml": "groups: []\n",
+
+	"apps/team:a.app/kustomization.yaml": `resources:
+- ../../base
+components:
+- ../../components/monitoring
+helmCharts:
+- name: postgres
+  repo: https://charts.example.com
+  version: 1.2.3
+- name: local-chart
+`,
+	"apps/with_under-score/kustomization.yaml": `bases:
+- ../../base
+helmChartInflationGenerator:
+- chartName: postgres
+  chartRepoUrl: https://charts.example.com
+  chartVersion: 1.2.3
+`,
+	"apps/dotted.name-1/kustomization.yaml": `resources:
+- ../with_under-score
+- ../missing
+patches:
+- path: missing-patch.yaml
+`,
+}
+
+func TestConformanceOfAllFormats(t *testing.T) {
+	fs := filesys.MakeFsInMemory()
+	for path, content := range conformanceFixture {
+		if err := fs.WriteFile(filepath.Join(filesys.Separator, path), []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// フラグの既定値を入れるために、コマンドラインとして読ませる
+	if _, err := kingpin.CommandLine.Parse([]string{"conformance", "--detail", "--jobs", "4", "--loglevel", "error", filesys.Separator}); err != nil {
+		t.Fatal(err)
+	}
+
+	resetGraph()
+	if err := scanRoots(fs, *roots); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 5 || len(helmCharts) != 2 || len(inlineReplacements) != 1 {
+		t.Fatalf("unexpected scan result: %d nodes, %d helm charts, %d inline replacements", len(nodes), len(helmCharts), len(inlineReplacements))
+	}
+
+	var names []string
+	for name := range conformanceFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			format := conformanceFormats[name]
+			var buf bytes.Buffer
+			if err := format.print(&buf); err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			shape, diffs, err := checkConformance(name, format, buf.Bytes())
+			if err != nil {
+				t.Fatalf("failed to parse: %v\n%s", err, buf.String())
+			}
+			for _, diff := range diffs {
+				t.Error(diff)
+			}
+			if len(diffs) > 0 {
+				t.Logf("output:\n%s", buf.String())
+			}
+			if shape.Nodes != nil && len(shape.Nodes) == 0 {
+				t.Error("no nodes in the output")
+			}
+		})
+	}
+}
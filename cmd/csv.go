@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var nodesCSV = kingpin.Flag("nodes-csv", "with --format csv, also write node metadata to this file").String()
+
+func printEdgesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"src", "dst", "type"})
+	for _, edge := range edges {
+		cw.Write([]string{edge.Src, edge.Dst, string(edge.Type)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	if *nodesCSV == "" {
+		return nil
+	}
+	f, err := os.Create(*nodesCSV)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return printNodesCSV(f)
+}
+
+func printNodesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "label", "type", "cluster", "inDegree", "outDegree", "fingerprint", "features", "fileSize", "entries", "patchLines"})
+
+	inDegree := map[string]int{}
+	outDegree := map[string]int{}
+	for _, edge := range edges {
+		outDegree[edge.Src]++
+		inDegree[edge.Dst]++
+	}
+
+	for _, node := range flatNodes() {
+		var fingerprint, features, fileSize, entries, patchLines string
+		if n, ok := nodes[node.ID]; ok {
+			fingerprint = n.Fingerprint
+			features = strings.Join(n.Features, " ")
+			fileSize = strconv.Itoa(n.Metrics.FileSize)
+			entries = strconv.Itoa(n.Metrics.TotalEntries)
+			patchLines = strconv.Itoa(n.Metrics.PatchLines)
+		}
+		cw.Write([]string{
+			node.ID,
+			node.Label,
+			string(node.Type),
+			node.Cluster,
+			strconv.Itoa(inDegree[node.ID]),
+			strconv.Itoa(outDegree[node.ID]),
+			fingerprint,
+			features,
+			fileSize,
+			entries,
+			patchLines,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
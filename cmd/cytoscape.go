@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type cytoscapeElements struct {
+	Nodes []cytoscapeElement `json:"nodes"`
+	Edges []cytoscapeElement `json:"edges"`
+}
+
+type cytoscapeElement struct {
+	Data map[string]string `json:"data"`
+}
+
+func printCytoscapeJSON(w io.Writer) error {
+	elements := cytoscapeElements{Nodes: []cytoscapeElement{}, Edges: []cytoscapeElement{}}
+
+	for _, node := range flatNodes() {
+		elements.Nodes = append(elements.Nodes, cytoscapeElement{Data: map[string]string{
+			"id":      node.ID,
+			"label":   node.Label,
+			"type":    string(node.Type),
+			"cluster": node.Cluster,
+		}})
+	}
+	// ノードと同じ名前空間なので、パスとぶつからない ID にする。種類違いの同じエッジがあるので種類も入れる
+	for _, edge := range edges {
+		elements.Edges = append(elements.Edges, cytoscapeElement{Data: map[string]string{
+			"id":     fmt.Sprintf("edge:%s->%s:%s", edge.Src, edge.Dst, edge.Type),
+			"source": edge.Src,
+			"target": edge.Dst,
+			"type":   string(edge.Type),
+		}})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(map[string]interface{}{"elements": elements})
+}
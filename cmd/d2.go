@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var d2Shapes = map[NodeType]string{
+	NodeTypeKustomization: "rectangle",
+	NodeTypeComponent:     "package",
+	NodeTypeHelmChart:     "cylinder",
+	NodeTypeFile:          "page",
+	NodeTypeUnknown:       "rectangle",
+	NodeTypePlaceholder:   "text",
+}
+
+// コンテナの中のノードはキーを "." でつないで参照するので、キーは c0, n0, ... の形にしてラベルを別に付ける
+func printD2(w io.Writer) error {
+	flat := flatNodes()
+	keys := map[string]string{}
+	printD2Cluster(w, clusterTree(flat), "", keys, new(int), 0)
+
+	for _, edge := range edges {
+		if edge.Type == EdgeTypeResource || edge.Type == EdgeTypeComponent {
+			fmt.Fprintf(w, "%s -> %s\n", keys[edge.Src], keys[edge.Dst])
+		} else {
+			fmt.Fprintf(w, "%s -> %s: {style.stroke-dash: 3}\n", keys[edge.Src], keys[edge.Dst])
+		}
+	}
+	return nil
+}
+
+func printD2Cluster(w io.Writer, t *ClusterTree, prefix string, keys map[string]string, clusters *int, indentLevel int) {
+	indent := strings.Repeat(" ", 2*indentLevel)
+
+	for _, node := range t.Nodes {
+		key := fmt.Sprintf("n%d", len(keys))
+		keys[node.ID] = prefix + key
+		fmt.Fprintf(w, indent+"%s: %s {shape: %s}\n", key, quoteD2(node.Label), d2Shapes[node.Type])
+	}
+	for _, name := range t.ChildNames() {
+		key := fmt.Sprintf("c%d", *clusters)
+		*clusters++
+		fmt.Fprintf(w, indent+"%s: %s {\n", key, quoteD2(name))
+		printD2Cluster(w, t.Children[name], prefix+key+".", keys, clusters, indentLevel+1)
+		fmt.Fprintln(w, indent+"}")
+	}
+}
+
+func quoteD2(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// テスト用・サンプル用のディレクトリはデフォルトで目立たなくする
+var defaultDeemphasizePatterns = []string{
+	`(^|/)(test|tests|testdata|e2e)(/|$)`,
+	`(^|/)(example|examples|demo|demos|sample|samples)(/|$)`,
+}
+
+const collapsedNodeName = "(deemphasized)"
+
+var deemphasizeRegexps []*regexp.Regexp
+
+func compileDeemphasizePatterns(patterns []string) error {
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid de-emphasize pattern %q: %w", p, err)
+		}
+		deemphasizeRegexps = append(deemphasizeRegexps, re)
+	}
+	return nil
+}
+
+func isDeemphasized(path string) bool {
+	slashed := filepath.ToSlash(path)
+	for _, re := range deemphasizeRegexps {
+		if re.MatchString(slashed) {
+			return true
+		}
+	}
+	return false
+}
+
+// collapse 有効時は、de-emphasize 対象のノードを同じクラスタ内の 1 ノードにまとめる
+func displayNodeID(path string) string {
+	if *collapseDeemphasized && isDeemphasized(path) {
+		return filepath.Join(filepath.Dir(path), collapsedNodeName)
+	}
+	return path
+}
+
+const (
+	mutedNodeAttrs = `fontcolor=gray55, fillcolor=gray95, style="filled,dashed", color=gray70`
+	mutedEdgeAttrs = `color=gray70, style=dashed`
+)
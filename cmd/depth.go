@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"golang.org/x/exp/slices"
+)
+
+var maxDepth = kingpin.Flag("max-depth", "follow references at most N levels from the entry kustomizations; deeper nodes are collapsed into a placeholder with a count in graph and merge output (0: unlimited)").Default("0").Int()
+
+const depthPlaceholderName = "(deeper)"
+
+// --max-depth で折りたたんだノードの代わりに置くノードと、隠したノードの数
+var depthPlaceholders = map[string]int{}
+
+func isBeyondMaxDepth(depth int) bool {
+	return *maxDepth > 0 && depth > *maxDepth
+}
+
+// 誰からも参照されていないノードを起点にした最短の深さ。循環だけでできた部分はその中の最初のノードを起点にする
+func nodeDepths() map[string]int {
+	deps := map[string][]string{}
+	inDegree := map[string]int{}
+	for _, edge := range edges {
+		deps[edge.Src] = append(deps[edge.Src], edge.Dst)
+		inDegree[edge.Dst]++
+	}
+
+	depths := map[string]int{}
+	bfs := func(starts []string) {
+		queue := []string{}
+		for _, id := range starts {
+			if _, ok := depths[id]; !ok {
+				depths[id] = 0
+				queue = append(queue, id)
+			}
+		}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, next := range deps[id] {
+				if _, ok := depths[next]; !ok {
+					depths[next] = depths[id] + 1
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+
+	var entries []string
+	all := flatNodes()
+	for _, node := range all {
+		if inDegree[node.ID] == 0 {
+			entries = append(entries, node.ID)
+		}
+	}
+	bfs(entries)
+	for _, node := range all {
+		bfs([]string{node.ID})
+	}
+	return depths
+}
+
+// 深すぎるノードをグラフから除き、境界のノードごとに隠した数を持つプレースホルダーを置く
+func limitDepth() {
+	if *maxDepth <= 0 {
+		return
+	}
+	depths := nodeDepths()
+	hidden := func(id string) bool {
+		return isBeyondMaxDepth(depths[id])
+	}
+
+	deps := map[string][]string{}
+	for _, edge := range edges {
+		deps[edge.Src] = append(deps[edge.Src], edge.Dst)
+	}
+	countHidden := func(from string) int {
+		visited := map[string]bool{}
+		var walk func(id string)
+		walk = func(id string) {
+			for _, next := range deps[id] {
+				if hidden(next) && !visited[next] {
+					visited[next] = true
+					walk(next)
+				}
+			}
+		}
+		walk(from)
+		return len(visited)
+	}
+
+	var kept []Edge
+	for _, edge := range edges {
+		switch {
+		case hidden(edge.Src):
+		case hidden(edge.Dst):
+			placeholder := filepath.Join(edge.Src, depthPlaceholderName)
+			if _, ok := depthPlaceholders[placeholder]; !ok {
+				depthPlaceholders[placeholder] = countHidden(edge.Src)
+				kept = append(kept, Edge{Src: edge.Src, Dst: placeholder, Type: EdgeTypeResource})
+			}
+		default:
+			kept = append(kept, edge)
+		}
+	}
+	edges = kept
+
+	var ids []string
+	for id := range depths {
+		if hidden(id) {
+			ids = append(ids, id)
+		}
+	}
+	removeNodes(ids)
+}
+
+// ノードをディレクトリツリーごと取り除く。エッジは呼び出し側で始末する
+func removeNodes(ids []string) {
+	removed := map[string]bool{}
+	for _, id := range ids {
+		removed[id] = true
+		delete(nodes, id)
+		d := rootDir.Parent(id)
+		if i := slices.Index(d.Kustomizations, filepath.Base(id)); i >= 0 {
+			d.Kustomizations = slices.Delete(d.Kustomizations, i, i+1)
+		}
+		if i := slices.Index(d.Files, id); i >= 0 {
+			d.Files = slices.Delete(d.Files, i, i+1)
+		}
+	}
+	var charts []HelmChartNode
+	for _, chart := range helmCharts {
+		if !removed[chart.ID()] {
+			charts = append(charts, chart)
+		}
+	}
+	helmCharts = charts
+	pruneEmptyDirNodes(&rootDir)
+}
+
+func pruneEmptyDirNodes(node *DirNode) bool {
+	for name, child := range node.Children {
+		if pruneEmptyDirNodes(child) {
+			delete(node.Children, name)
+		}
+	}
+	return len(node.Kustomizations) == 0 && len(node.Files) == 0 && len(node.Children) == 0
+}
+
+func depthPlaceholderLabel(id string) string {
+	return fmt.Sprintf("+%d deeper", depthPlaceholders[id])
+}
+
+func printDepthPlaceholders(w io.Writer, indentLevel int) {
+	if len(depthPlaceholders) == 0 {
+		return
+	}
+	indent := strings.Repeat(" ", 2*indentLevel)
+
+	var ids []string
+	for id := range depthPlaceholders {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	fmt.Fprintln(w, "")
+	for _, id := range ids {
+		fmt.Fprintf(w, indent+"%s  [label=\"%s\", shape=folder, %s]\n", dotID(id), depthPlaceholderLabel(id), mutedNodeAttrs)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	fileNodeAttrs        = `shape=note, fillcolor=white, fontsize=10`
+	missingFileNodeAttrs = `shape=note, fillcolor=mistyrose, fontcolor=red, fontsize=10`
+	fileEdgeAttrs        = `color=gray50, style=dotted, arrowhead=vee`
+)
+
+var missingFiles = map[string]bool{}
+
+func printFileNodes(w io.Writer, node *DirNode, dirName string, indentLevel int) {
+	indent := strings.Repeat(" ", 2*indentLevel)
+
+	for _, file := range node.Files {
+		printFileNode(w, indent, file)
+	}
+}
+
+func printFileNode(w io.Writer, indent string, file string) {
+	label := filepath.Join(filepath.Base(filepath.Dir(file)), filepath.Base(file))
+	if l, ok := inlineReplacements[file]; ok {
+		fmt.Fprintf(w, indent+"%s  [label=\"%s\", %s]\n", dotID(file), escapeDOT(l, `\n`), inlineReplacementNodeAttrs)
+	} else if missingFiles[file] {
+		fmt.Fprintf(w, indent+"%s  [label=\"%s\\n(not found)\", %s]\n", dotID(file), escapeDOT(label, `\n`), missingFileNodeAttrs)
+	} else {
+		fmt.Fprintf(w, indent+"%s  [label=\"%s\", %s%s]\n", dotID(file), escapeDOT(label, `\n`), fileNodeAttrs, linkAttrs(file))
+	}
+}
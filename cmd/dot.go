@@ -0,0 +1,15 @@
+package main
+
+import "strings"
+
+// DOT の文字列リテラル用。ラベルでは改行を左寄せ (\l) にする
+func escapeDOT(s string, newline string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", newline)
+}
+
+// ノードやクラスタの ID は常に引用符で囲み、パスに使える文字をそのまま通す
+func dotID(s string) string {
+	return `"` + escapeDOT(s, `\n`) + `"`
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var edgeLabels = kingpin.Flag("edge-labels", "label each edge with the kustomization.yaml entry (field, index and value) that created it").Bool()
+
+// 参照元・参照先の組ごとの、参照を作った entry (例: "resources[1]: ../base")
+var edgeEntries = map[Edge][]string{}
+
+// --merge-leaves でまとめた数と --edge-labels の entry を 1 つのラベルにする
+func edgeLabelAttrs(edge Edge) string {
+	key := Edge{Src: edge.Src, Dst: edge.Dst}
+
+	var lines []string
+	if n := edgeMultiplicity[key]; n > 1 {
+		lines = append(lines, fmt.Sprintf("×%d", n))
+	}
+	if *edgeLabels {
+		lines = append(lines, edgeEntries[key]...)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`label="%s"`, escapeDOT(strings.Join(lines, "\n"), `\n`))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+var (
+	rootsJSON  = rootsCmd.Flag("json", "print the list as a JSON array").Bool()
+	leavesJSON = leavesCmd.Flag("json", "print the list as a JSON array").Bool()
+)
+
+// Component は単体でビルドできないので、エントリポイントには数えない
+func findRoots() []string {
+	referenced := map[string]bool{}
+	for _, edge := range edges {
+		if isKustomizationEdge(edge) {
+			referenced[edge.Dst] = true
+		}
+	}
+	paths := []string{}
+	for path, node := range nodes {
+		if !referenced[path] && node.Kind != "Component" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// 参照先がディレクトリだけで kustomization でなくても、参照しているものとして数える
+func findLeaves() []string {
+	references := map[string]bool{}
+	for _, edge := range edges {
+		if isKustomizationEdge(edge) {
+			references[edge.Src] = true
+		}
+	}
+	paths := []string{}
+	for path := range nodes {
+		if !references[path] {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func printPathList(w io.Writer, paths []string, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(paths)
+	}
+	for _, path := range paths {
+		fmt.Fprintln(w, path)
+	}
+	return nil
+}
+
+func printRoots(w io.Writer) error {
+	return printPathList(w, findRoots(), *rootsJSON)
+}
+
+func printLeaves(w io.Writer) error {
+	return printPathList(w, findLeaves(), *leavesJSON)
+}
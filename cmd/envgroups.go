@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var (
+	groupByEnv = kingpin.Flag("group-by-env", "in DOT output, cluster nodes by environment (see --env-pattern / --env-segment) instead of directory nesting").Bool()
+	envPattern = kingpin.Flag("env-pattern", "regexp matched against the relative path; the first capture group (or the whole match) names the environment").Default(`(?:^|/)(dev|develop|development|stg|stage|staging|prod|production|qa)(?:/|$)`).String()
+	envSegment = kingpin.Flag("env-segment", "use the N-th path segment (1-based) as the environment instead of --env-pattern (e.g. 2 for overlays/<env>/...)").Int()
+)
+
+var envRegexp *regexp.Regexp
+
+func compileEnvPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid env pattern %q: %w", pattern, err)
+	}
+	envRegexp = re
+	return nil
+}
+
+// 環境に属さないもの (共有の base など) は空文字
+func environmentOf(path string) string {
+	slashed := filepath.ToSlash(path)
+	if *envSegment > 0 {
+		segments := strings.Split(slashed, "/")
+		if len(segments) < *envSegment {
+			return ""
+		}
+		return segments[*envSegment-1]
+	}
+
+	m := envRegexp.FindStringSubmatch(slashed)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1 && m[1] != "":
+		return m[1]
+	default:
+		return m[0]
+	}
+}
+
+type envGroup struct {
+	kustomizations []string
+	files          []string
+}
+
+func collectEnvGroups(node *DirNode, dirName string, groups map[string]*envGroup) {
+	group := func(path string) *envGroup {
+		env := environmentOf(path)
+		if groups[env] == nil {
+			groups[env] = &envGroup{}
+		}
+		return groups[env]
+	}
+	for _, kustomization := range node.Kustomizations {
+		path := filepath.Join(dirName, kustomization)
+		g := group(path)
+		g.kustomizations = append(g.kustomizations, path)
+	}
+	for _, file := range node.Files {
+		g := group(file)
+		g.files = append(g.files, file)
+	}
+	for childName, child := range node.Children {
+		collectEnvGroups(child, filepath.Join(dirName, childName), groups)
+	}
+}
+
+// ディレクトリの入れ子を崩すので、ラベルには相対パスをそのまま使う
+func printEnvGroupedNodes(w io.Writer, indentLevel int) {
+	indent := strings.Repeat(" ", 2*indentLevel)
+	nextIndent := strings.Repeat(" ", 2*(indentLevel+1))
+
+	groups := map[string]*envGroup{}
+	collectEnvGroups(&rootDir, "", groups)
+
+	var envs []string
+	for env := range groups {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	for _, env := range envs {
+		if env == "" {
+			printEnvGroupNodes(w, indent, groups[env])
+			continue
+		}
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, indent+"subgraph %s {\n", dotID("cluster_env:"+env))
+		printClusterStyle(w, nextIndent, env)
+		printEnvGroupNodes(w, nextIndent, groups[env])
+		fmt.Fprintln(w, indent+"}")
+	}
+}
+
+func printEnvGroupNodes(w io.Writer, indent string, group *envGroup) {
+	sort.Strings(group.kustomizations)
+	sort.Strings(group.files)
+
+	collapsed := map[string]int{}
+	var collapsedIDs []string
+	for _, path := range group.kustomizations {
+		if *collapseDeemphasized && isDeemphasized(path) {
+			id := displayNodeID(path)
+			if collapsed[id] == 0 {
+				collapsedIDs = append(collapsedIDs, id)
+			}
+			collapsed[id]++
+			continue
+		}
+		printKustomizationNode(w, indent, path, path)
+	}
+	for _, id := range collapsedIDs {
+		printCollapsedNode(w, indent, id, collapsed[id])
+	}
+	for _, file := range group.files {
+		printFileNode(w, indent, file)
+	}
+}
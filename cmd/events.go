@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+type (
+	Event     = graph.Event
+	EventType = graph.EventType
+)
+
+const (
+	EventDiscover = graph.EventDiscover
+	EventParse    = graph.EventParse
+	EventEdge     = graph.EventEdge
+	EventIssue    = graph.EventIssue
+	EventDone     = graph.EventDone
+)
+
+// nil のときはイベントを出力しない
+var eventEncoder *json.Encoder
+var eventMu sync.Mutex
+
+func enableEvents(w io.Writer) {
+	eventEncoder = json.NewEncoder(w)
+}
+
+func emitEvent(e Event) {
+	countProgress(e.Type)
+	if eventEncoder == nil {
+		return
+	}
+	eventMu.Lock()
+	defer eventMu.Unlock()
+
+	if !*reproducible {
+		e.Time = time.Now().Format(time.RFC3339Nano)
+	}
+	eventEncoder.Encode(e)
+}
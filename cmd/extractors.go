@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var extractorPlugins = kingpin.Flag("extractor-plugin", "shell command that reports extra references of each kustomization (e.g. manifests read by KRM function configs); it gets the kustomization as JSON on stdin and prints {\"references\": [{\"field\", \"path\", \"type\"}]} (repeatable)").Strings()
+
+// --extractor-plugin のコマンドには、kustomization ごとに次の JSON を stdin で渡す。
+//
+//	{"apiVersion": "kustomize-graphing/v1", "kind": "ExtractRequest",
+//	 "dir": "/abs/path/to/app", "path": "app", "kustomization": {...}}
+//
+// コマンドは kustomization のディレクトリで実行され (--input のときを除く)、stdout に次の JSON を返す。
+//
+//	{"references": [{"field": "transformers/fn.yaml", "path": "../shared/config.yaml"},
+//	                {"field": "transformers/fn.yaml", "path": "../base", "type": "component"}]}
+type execExtractor struct {
+	command string
+}
+
+type extractRequest struct {
+	APIVersion    string          `json:"apiVersion"`
+	Kind          string          `json:"kind"`
+	Dir           string          `json:"dir"`
+	Path          string          `json:"path"`
+	Kustomization json.RawMessage `json:"kustomization"`
+}
+
+type extractResponse struct {
+	References []graph.ExtractedReference `json:"references"`
+}
+
+func extractors() []graph.ReferenceExtractor {
+	var es []graph.ReferenceExtractor
+	for _, command := range *extractorPlugins {
+		es = append(es, execExtractor{command: command})
+	}
+	return es
+}
+
+func (e execExtractor) Name() string {
+	return e.command
+}
+
+func (e execExtractor) Extract(fs filesys.FileSystem, dir string, rel string, kustomization *types.Kustomization, data []byte) ([]graph.ExtractedReference, error) {
+	raw, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := json.Marshal(extractRequest{
+		APIVersion:    "kustomize-graphing/v1",
+		Kind:          "ExtractRequest",
+		Dir:           canonicalPath(dir),
+		Path:          rel,
+		Kustomization: raw,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", e.command)
+	if onDisk {
+		cmd.Dir = dir
+	}
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	var res extractResponse
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	for _, ref := range res.References {
+		if ref.Type != "" && ref.Type != "resource" && ref.Type != "component" {
+			return nil, fmt.Errorf("invalid reference type %q for %s (expected 'resource' or 'component')", ref.Type, ref.Path)
+		}
+	}
+	return res.References, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+func printFeatureReport(w io.Writer) error {
+	usage := map[string][]string{}
+	fingerprints := map[string]int{}
+	fingerprintFeatures := map[string][]string{}
+	for path, node := range nodes {
+		for _, feature := range node.Features {
+			usage[feature] = append(usage[feature], path)
+		}
+		fingerprints[node.Fingerprint]++
+		fingerprintFeatures[node.Fingerprint] = node.Features
+	}
+
+	var features []string
+	for feature := range usage {
+		features = append(features, feature)
+	}
+	sort.Slice(features, func(i, j int) bool {
+		if len(usage[features[i]]) != len(usage[features[j]]) {
+			return len(usage[features[i]]) > len(usage[features[j]])
+		}
+		return features[i] < features[j]
+	})
+
+	fmt.Fprintf(w, "%d kustomizations, %d distinct feature fingerprints\n\n", len(nodes), len(fingerprints))
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FEATURE\tKUSTOMIZATIONS\tADOPTION\tNOTE")
+	for _, feature := range features {
+		note := ""
+		if replacement, ok := graph.DeprecatedFeatures[feature]; ok {
+			note = "deprecated, use " + replacement
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%s\n", feature, len(usage[feature]), 100*float64(len(usage[feature]))/float64(len(nodes)), note)
+	}
+	tw.Flush()
+
+	var deprecated []string
+	for _, feature := range features {
+		if _, ok := graph.DeprecatedFeatures[feature]; ok {
+			deprecated = append(deprecated, feature)
+		}
+	}
+	if len(deprecated) > 0 {
+		fmt.Fprintln(w, "\nkustomizations using deprecated features:")
+		for _, feature := range deprecated {
+			paths := usage[feature]
+			sort.Strings(paths)
+			fmt.Fprintf(w, "  %s:\n", feature)
+			for _, path := range paths {
+				fmt.Fprintf(w, "    %s\n", path)
+			}
+		}
+	}
+
+	var fps []string
+	for fp := range fingerprints {
+		fps = append(fps, fp)
+	}
+	sort.Slice(fps, func(i, j int) bool {
+		if fingerprints[fps[i]] != fingerprints[fps[j]] {
+			return fingerprints[fps[i]] > fingerprints[fps[j]]
+		}
+		return fps[i] < fps[j]
+	})
+	fmt.Fprintln(w, "\nfingerprints:")
+	for _, fp := range fps {
+		fmt.Fprintf(w, "  %s  %d  [%s]\n", fp, fingerprints[fp], strings.Join(fingerprintFeatures[fp], " "))
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alecthomas/kingpin"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var findingsOutput = kingpin.Flag("findings-output", "write problems found while scanning (missing references, build failures) or by lint as JSON to this file, regardless of --loglevel ('-' for stdout)").String()
+
+type (
+	Finding  = graph.Finding
+	Severity = graph.Severity
+)
+
+const (
+	SeverityError   = graph.SeverityError
+	SeverityWarning = graph.SeverityWarning
+	SeverityInfo    = graph.SeverityInfo
+)
+
+// 走査結果の Finding に --severity-overrides と --baseline を反映したもの
+func collectFindings() []Finding {
+	findings := (&graph.Graph{Nodes: nodes, NotFound: notFoundRefs}).Findings()
+	for i := range findings {
+		applyFindingPolicy(&findings[i])
+	}
+	return findings
+}
+
+func printFindings(w io.Writer, findings []Finding) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(findings)
+}
+
+// lint の結果もこの形式で書くので、そのまま --baseline に使える
+func writeFindings(path string, findings []Finding) error {
+	if path == "" {
+		return nil
+	}
+	if path == "-" {
+		// グラフと stdout で混ざらないようにする
+		if *output == "" || *output == "-" {
+			return fmt.Errorf("--findings-output - writes findings to stdout; use --output for the graph")
+		}
+		return printFindings(os.Stdout, findings)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return printFindings(f, findings)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var (
+	fixturesCmd         = kingpin.Command("fixtures", "developer tools for synthetic manifest trees")
+	fixturesGenerateCmd = fixturesCmd.Command("generate", "generate a synthetic kustomization tree for benchmarks and tests")
+	fixturesDir         = fixturesGenerateCmd.Arg("outDir", "directory to generate the tree into").Required().String()
+	fixturesDepth       = fixturesGenerateCmd.Flag("depth", "number of layers (bases are layer 0)").Default("3").Int()
+	fixturesWidth       = fixturesGenerateCmd.Flag("width", "number of kustomizations per layer").Default("10").Int()
+	fixturesFanOut      = fixturesGenerateCmd.Flag("fan-out", "number of kustomizations each kustomization references in the layer below").Default("2").Int()
+	fixturesBrokenRate  = fixturesGenerateCmd.Flag("broken-ref-rate", "probability (0-1) that a reference points to a missing path").Default("0").Float64()
+	fixturesManifests   = fixturesGenerateCmd.Flag("manifests", "number of plain manifests per kustomization").Default("1").Int()
+	fixturesSeed        = fixturesGenerateCmd.Flag("seed", "random seed (same seed, same tree)").Default("1").Int64()
+	fixturesForce       = fixturesGenerateCmd.Flag("force", "generate even if outDir is not empty").Bool()
+)
+
+func fixtureDir(layer int, index int) string {
+	return filepath.Join(fmt.Sprintf("layer-%02d", layer), fmt.Sprintf("kust-%04d", index))
+}
+
+func generateFixtures() error {
+	if *fixturesDepth < 1 || *fixturesWidth < 1 {
+		return fmt.Errorf("--depth and --width must be positive")
+	}
+	if *fixturesFanOut < 0 || *fixturesManifests < 0 {
+		return fmt.Errorf("--fan-out and --manifests must not be negative")
+	}
+	if *fixturesBrokenRate < 0 || *fixturesBrokenRate > 1 {
+		return fmt.Errorf("--broken-ref-rate must be between 0 and 1")
+	}
+	if entries, err := os.ReadDir(*fixturesDir); err == nil && len(entries) > 0 && !*fixturesForce {
+		return fmt.Errorf("%s is not empty; use --force to generate anyway", *fixturesDir)
+	}
+
+	rnd := rand.New(rand.NewSource(*fixturesSeed))
+	broken := 0
+	total := 0
+
+	for layer := 0; layer < *fixturesDepth; layer++ {
+		for i := 0; i < *fixturesWidth; i++ {
+			dir := filepath.Join(*fixturesDir, fixtureDir(layer, i))
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+
+			var resources []string
+			for m := 0; m < *fixturesManifests; m++ {
+				name := fmt.Sprintf("configmap-%d.yaml", m)
+				manifest := fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s-%d\ndata:\n  layer: \"%d\"\n", strings.ReplaceAll(fixtureDir(layer, i), "/", "-"), m, layer)
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(manifest), 0o644); err != nil {
+					return err
+				}
+				resources = append(resources, name)
+			}
+
+			if layer > 0 {
+				// 下の層からかぶらないように fan-out 個選ぶ
+				for _, j := range rnd.Perm(*fixturesWidth)[:minInt(*fixturesFanOut, *fixturesWidth)] {
+					ref := filepath.Join("..", "..", fixtureDir(layer-1, j))
+					if rnd.Float64() < *fixturesBrokenRate {
+						ref = filepath.Join("..", "..", fmt.Sprintf("layer-%02d", layer-1), fmt.Sprintf("missing-%04d", j))
+						broken++
+					}
+					resources = append(resources, filepath.ToSlash(ref))
+					total++
+				}
+			}
+
+			var b strings.Builder
+			b.WriteString("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n")
+			for _, r := range resources {
+				fmt.Fprintf(&b, "- %s\n", r)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(b.String()), 0o644); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "generated %d kustomizations with %d references (%d broken) in %s\n", *fixturesDepth**fixturesWidth, total, broken, *fixturesDir)
+	return nil
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
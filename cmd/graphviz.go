@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var (
+	pipeDot   = kingpin.Flag("pipe-dot", "shell command the DOT output is piped into (e.g. 'dot -Tsvg -o graph.svg')").String()
+	render    = kingpin.Flag("render", "render the graph with Graphviz into this format (e.g. 'svg', 'png') instead of writing DOT").String()
+	dotBinary = kingpin.Flag("dot-binary", "Graphviz binary used by --render").Default("dot").String()
+)
+
+func useGraphviz() bool {
+	return *pipeDot != "" || *render != ""
+}
+
+func runGraphviz(print func(w io.Writer) error) error {
+	if *format != "dot" {
+		return fmt.Errorf("--pipe-dot and --render require --format dot")
+	}
+	if *pipeDot != "" && *render != "" {
+		return fmt.Errorf("--pipe-dot and --render cannot be used together")
+	}
+
+	var dot bytes.Buffer
+	if err := print(&dot); err != nil {
+		return err
+	}
+
+	if *pipeDot != "" {
+		cmd := exec.Command("sh", "-c", *pipeDot)
+		cmd.Stdin = &dot
+		cmd.Stdout = os.Stdout
+		return runGraphvizCommand(cmd, *pipeDot)
+	}
+	return writeOutput(func(w io.Writer) error {
+		return renderDot(w, &dot)
+	})
+}
+
+// dot の入力は一時ファイル経由で渡し、終わったら消す
+func renderDot(w io.Writer, dot io.Reader) error {
+	tmp, err := os.CreateTemp("", "kustomize-graphing-*.dot")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, dot); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(*dotBinary, "-T"+*render, tmp.Name())
+	cmd.Stdout = w
+	return runGraphvizCommand(cmd, *dotBinary)
+}
+
+func runGraphvizCommand(cmd *exec.Cmd, name string) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%s is not found in PATH; install Graphviz or set --dot-binary", name)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return fmt.Errorf("%s failed: %w", name, err)
+		}
+		return fmt.Errorf("%s failed: %w: %s", name, err, msg)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	return nil
+}
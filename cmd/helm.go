@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// helmChartInflationGenerator は FixKustomization で helmCharts に寄せられる
+var helmCharts = []HelmChartNode{}
+
+const helmChartEdgeAttrs = `color=steelblue, style=dashed`
+
+func printHelmChartNodes(w io.Writer, indentLevel int) {
+	if len(helmCharts) == 0 {
+		return
+	}
+
+	indent := strings.Repeat(" ", 2*indentLevel)
+	nextIndent := strings.Repeat(" ", 2*(indentLevel+1))
+
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, indent+"subgraph "+dotID("cluster_helm")+" {")
+	fmt.Fprintln(w, nextIndent+"label = \"helm charts\"")
+	fmt.Fprintln(w, nextIndent+"style=dashed;")
+	fmt.Fprintln(w, nextIndent+"color=steelblue;")
+	for _, chart := range helmCharts {
+		repo := chart.Repo
+		if repo == "" {
+			repo = "(local)"
+		}
+		version := chart.Version
+		if version == "" {
+			version = "(latest)"
+		}
+		label := escapeDOT(chart.Name+"\n"+version+"\n"+repo, `\n`)
+		fmt.Fprintf(w, nextIndent+"%s  [label=\"%s\", shape=cylinder, color=steelblue]\n", dotID(chart.ID()), label)
+	}
+	fmt.Fprintln(w, indent+"}")
+}
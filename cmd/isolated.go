@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/alecthomas/kingpin"
+	"go.uber.org/zap"
+)
+
+var hideIsolated = kingpin.Flag("hide-isolated", "omit kustomizations with neither dependencies nor dependents from the rendered graph (stats still count them)").Bool()
+
+func hideIsolatedNodes() {
+	connected := map[string]bool{}
+	for _, edge := range edges {
+		connected[edge.Src] = true
+		connected[edge.Dst] = true
+	}
+
+	var isolated []string
+	for path := range nodes {
+		if !connected[path] {
+			isolated = append(isolated, path)
+		}
+	}
+	zap.S().Debugf("hiding %d isolated kustomizations", len(isolated))
+	removeNodes(isolated)
+}
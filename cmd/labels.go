@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/alecthomas/kingpin"
+	"go.uber.org/zap"
+)
+
+var labelTemplate = kingpin.Flag("label-template", "Go template for kustomization node labels (fields: .Path, .Name, .Kind, .Namespace, .NamePrefix, .NameSuffix, .Resources, .Features, .Annotations)").String()
+
+var nodeLabelTmpl *template.Template
+
+type nodeLabelData struct {
+	*Node
+	Name string // ディレクトリ名 (デフォルトのラベル)
+}
+
+func compileLabelTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	tmpl, err := template.New("label").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return err
+	}
+	// 存在しないフィールドなどは、ノードごとに警告を出す前にここで弾く
+	if err := tmpl.Execute(io.Discard, nodeLabelData{Node: &Node{}}); err != nil {
+		return fmt.Errorf("invalid --label-template: %w", err)
+	}
+	nodeLabelTmpl = tmpl
+	return nil
+}
+
+// テンプレートがなければ fallback をそのまま使う
+func nodeLabel(path string, fallback string) string {
+	node, ok := nodes[path]
+	if nodeLabelTmpl == nil || !ok {
+		return fallback
+	}
+
+	var b strings.Builder
+	if err := nodeLabelTmpl.Execute(&b, nodeLabelData{Node: node, Name: filepath.Base(path)}); err != nil {
+		zap.S().Warnf("failed to render the label of %s: %v", path, err)
+		return fallback
+	}
+	return b.String()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var linkBaseURL = kingpin.Flag("link-base-url", "URL of topDir in the hosting forge (e.g. https://github.com/org/repo/tree/main/); nodes in DOT output link to their directory or file").String()
+
+// DOT のノード属性に付け足す URL 属性。--link-base-url がなければ空
+func linkAttrs(path string) string {
+	if *linkBaseURL == "" {
+		return ""
+	}
+	var segments []string
+	for _, s := range strings.Split(filepath.ToSlash(path), "/") {
+		segments = append(segments, url.PathEscape(s))
+	}
+	return fmt.Sprintf(`, URL="%s", target="_blank"`, strings.TrimSuffix(*linkBaseURL, "/")+"/"+strings.Join(segments, "/"))
+}
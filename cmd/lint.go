@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var (
+	lintCmd            = withTopDirArg(kingpin.Command("lint", "report broken references, deprecated fields, unknown keys and other problems kustomize would reject"))
+	lintReportFormat   = lintCmd.Flag("report-format", "findings output: 'text' or 'sarif'").Default("text").Enum("text", "sarif")
+	lintLoadRestrictor = lintCmd.Flag("load-restrictor", "kustomize load restrictor to validate file references against").Default("LoadRestrictionsRootOnly").Enum("LoadRestrictionsRootOnly", "LoadRestrictionsNone")
+	lintFailOn         = lintCmd.Flag("fail-on", "lowest severity of findings that makes lint exit non-zero: 'error', 'warning' or 'info'").Default("error").Enum("error", "warning", "info")
+)
+
+type LintRule string
+
+const (
+	LintRuleInvalidYAML     LintRule = "invalid-yaml"
+	LintRuleUnknownField    LintRule = "unknown-field"
+	LintRuleRejected        LintRule = "rejected" // kustomize の厳密な読み込みで弾かれるもの
+	LintRuleInvalidKind     LintRule = "invalid-kind"
+	LintRuleDeprecatedField LintRule = "deprecated-field"
+
+	LintRuleMissingReference   LintRule = graph.RuleMissingReference
+	LintRuleDuplicateReference LintRule = "duplicate-reference"
+	LintRuleInvalidReference   LintRule = "invalid-reference"
+	LintRuleLoadRestrictor     LintRule = "load-restrictor"
+	LintRuleUnusedFile         LintRule = "unused-file"
+)
+
+var lintRuleDescriptions = map[LintRule]string{
+	LintRuleInvalidYAML:        "kustomization.yaml is not valid YAML",
+	LintRuleUnknownField:       "unknown top-level field",
+	LintRuleRejected:           "kustomize rejects the kustomization",
+	LintRuleInvalidKind:        "invalid kind or apiVersion",
+	LintRuleDeprecatedField:    "deprecated field",
+	LintRuleMissingReference:   "referenced path is not found",
+	LintRuleDuplicateReference: "path is referenced more than once",
+	LintRuleInvalidReference:   "directory referenced where a file is expected",
+	LintRuleLoadRestrictor:     "file outside the kustomization root",
+	LintRuleUnusedFile:         "YAML file in a kustomization directory is not referenced",
+}
+
+var (
+	yamlErrorLine    = regexp.MustCompile(`line (\d+)`)
+	unknownFieldName = regexp.MustCompile(`unknown field "([^"]+)"`)
+)
+
+// field の値の中で value に一致するスカラーの行。value が空なら field のキーの行。見つからなければ 1
+func findingLine(data []byte, field string, value string) int {
+	if lines := findingLines(data, field, value); len(lines) > 0 {
+		return lines[0]
+	}
+	return 1
+}
+
+func findingLines(data []byte, field string, value string) []int {
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+
+	var lines []int
+	var search func(n *yamlv3.Node)
+	search = func(n *yamlv3.Node) {
+		// files は "[key=]path" 形式
+		if n.Kind == yamlv3.ScalarNode && (n.Value == value || strings.HasSuffix(n.Value, "="+value)) {
+			lines = append(lines, n.Line)
+		}
+		for _, c := range n.Content {
+			search(c)
+		}
+	}
+
+	if root.Kind == yamlv3.MappingNode && field != "" {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if !strings.EqualFold(root.Content[i].Value, field) {
+				continue
+			}
+			if value == "" {
+				return []int{root.Content[i].Line}
+			}
+			search(root.Content[i+1])
+		}
+	}
+	// FixKustomization で別のフィールドから移されたもの (bases など)
+	if len(lines) == 0 && value != "" {
+		search(root)
+	}
+	return lines
+}
+
+// --severity-overrides と --baseline は graph の Finding と同じように効く
+type LintFinding struct {
+	Finding
+	File string // kustomization.yaml (unused-file では対象のファイル) の topDir からの相対パス
+	Line int
+}
+
+func newLintFinding(rule LintRule, kustomization string, field string, path string, message string, file string, line int) LintFinding {
+	return LintFinding{
+		Finding: Finding{
+			Severity:      lintSeverity(rule),
+			Kustomization: kustomization,
+			Field:         field,
+			Path:          path,
+			Rule:          string(rule),
+			Message:       message,
+		},
+		File: file,
+		Line: line,
+	}
+}
+
+var severityRanks = map[Severity]int{
+	SeverityInfo:    0,
+	SeverityWarning: 1,
+	SeverityError:   2,
+}
+
+func lintSeverity(rule LintRule) Severity {
+	if rule == LintRuleDeprecatedField || rule == LintRuleUnusedFile {
+		return SeverityWarning
+	}
+	return SeverityError
+}
+
+// Kustomization のトップレベルに書けるキー (json タグから)
+func kustomizationFields() map[string]bool {
+	fields := map[string]bool{}
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" && strings.Contains(opts, "inline") {
+				collect(f.Type)
+				continue
+			}
+			if name != "" && name != "-" {
+				fields[strings.ToLower(name)] = true
+			}
+		}
+	}
+	collect(reflect.TypeOf(types.Kustomization{}))
+	return fields
+}
+
+func lintKustomization(fs filesys.FileSystem, dir string, known map[string]bool) ([]LintFinding, error) {
+	path := filepath.Join(dir, "kustomization.yaml")
+	file, err := relPath(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := relPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []LintFinding
+	add := func(rule LintRule, field string, line int, format string, args ...interface{}) {
+		findings = append(findings, newLintFinding(rule, rel, field, "", fmt.Sprintf(format, args...), file, line))
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		line := 1
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+		add(LintRuleInvalidYAML, "", line, "%v", err)
+		return findings, nil
+	}
+
+	// kustomize はキーの大文字小文字を区別しない
+	var unknown []string
+	for key := range raw {
+		if !known[strings.ToLower(key)] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		add(LintRuleUnknownField, key, findingLine(data, key, ""), "unknown field %q", key)
+	}
+
+	var k types.Kustomization
+	strictErr := k.Unmarshal(data)
+	if strictErr != nil {
+		// トップレベルの未知のキーは上で報告済み。ネストしたフィールドや型の誤りはここで拾う
+		if len(unknown) == 0 {
+			line := 1
+			if m := unknownFieldName.FindStringSubmatch(strictErr.Error()); m != nil {
+				line = findingLine(data, "", m[1])
+			}
+			add(LintRuleRejected, "", line, "%v", strictErr)
+		}
+		if err := yaml.Unmarshal(data, &k); err != nil {
+			return findings, nil
+		}
+	}
+
+	for _, msg := range k.EnforceFields() {
+		field := "kind"
+		if strings.HasPrefix(msg, "apiVersion") {
+			field = "apiVersion"
+		}
+		add(LintRuleInvalidKind, field, findingLine(data, field, ""), "%s", msg)
+	}
+	for _, feature := range graph.DetectFeatures(&k) {
+		if replacement, ok := graph.DeprecatedFeatures[feature]; ok {
+			field, _, _ := strings.Cut(feature, ".")
+			add(LintRuleDeprecatedField, feature, findingLine(data, field, ""), "%s is deprecated; use %s", feature, replacement)
+		}
+	}
+
+	// 参照先の確認は kustomize が読める kustomization だけ
+	if strictErr == nil {
+		issues, err := checkDir(fs, dir, *lintLoadRestrictor)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			// 参照先は走査の Finding と同じく topDir からの相対パスにして、--baseline を共有できるようにする
+			ref := issue.Ref
+			if r, err := relPath(filepath.Join(dir, issue.Ref)); err == nil {
+				ref = r
+			}
+			message := fmt.Sprintf("%s: %s %s", issue.Field, issue.Ref, issue.Message)
+			findings = append(findings, newLintFinding(issue.Rule, rel, issue.Field, ref, message, file, issue.Line))
+		}
+	}
+	return findings, nil
+}
+
+// グラフを作らずに、見つかった kustomization を 1 つずつ調べる (走査は未知のフィールドがあると止まるので)
+func lintRoots(fs filesys.FileSystem, roots []string) ([]LintFinding, error) {
+	known := kustomizationFields()
+
+	seen := map[string]bool{}
+	var dirs []string
+	var findings []LintFinding
+	for _, root := range roots {
+		for _, dir := range graph.FindKustomizationDirs(fs, root, scanOptions()) {
+			if key := canonicalPath(dir); seen[key] {
+				continue
+			} else {
+				seen[key] = true
+			}
+			dirs = append(dirs, dir)
+			f, err := lintKustomization(fs, dir, known)
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, f...)
+		}
+	}
+	if *lintUnusedFiles {
+		findings = append(findings, findUnusedFiles(fs, dirs)...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+func runLint() error {
+	findings, err := lintRoots(inputFileSystem(), graph.NormalizeRoots(*topDir, *roots))
+	if err != nil {
+		return err
+	}
+	for i := range findings {
+		applyFindingPolicy(&findings[i].Finding)
+	}
+
+	var results []Finding
+	for _, f := range findings {
+		results = append(results, f.Finding)
+	}
+	if err := writeFindings(*findingsOutput, results); err != nil {
+		return err
+	}
+
+	print := func(w io.Writer) error {
+		for _, f := range findings {
+			suffix := ""
+			if f.Suppressed {
+				suffix = " (in baseline)"
+			}
+			fmt.Fprintf(w, "%s:%d: [%s] %s%s\n", f.File, f.Line, f.Rule, f.Message, suffix)
+		}
+		return nil
+	}
+	if *lintReportFormat == "sarif" {
+		print = func(w io.Writer) error {
+			return printSARIF(w, findings)
+		}
+	}
+	err = writeOutput(print)
+	if err != nil {
+		return err
+	}
+
+	// baseline にあるものと --fail-on より軽いものでは失敗しない
+	n := 0
+	for _, f := range findings {
+		if !f.Suppressed && severityRanks[f.Severity] >= severityRanks[Severity(*lintFailOn)] {
+			n++
+		}
+	}
+	if n > 0 {
+		return fmt.Errorf("%d lint findings with severity %s or higher", n, *lintFailOn)
+	}
+	return nil
+}
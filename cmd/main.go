@@ -2,39 +2,117 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kingpin"
 	"go.uber.org/zap"
-	"golang.org/x/exp/slices"
-	"sigs.k8s.io/kustomize/api/types"
-	"sigs.k8s.io/kustomize/kyaml/filesys"
 
-	"github.com/ks-yuzu/kustomize-graphing/pkg/util"
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
 )
 
 var (
-	topDir   = kingpin.Arg("topDir", "manifest top directory").Default(".").String()
+	topDir = new(string)
+
+	graphCmd    = withTopDirArg(kingpin.Command("graph", "render the kustomization dependency graph").Default())
+	featuresCmd = withTopDirArg(kingpin.Command("features", "report kustomize feature adoption across the tree"))
+	tuiCmd      = withTopDirArg(kingpin.Command("tui", "explore the graph interactively in the terminal"))
+	statsCmd    = withTopDirArg(kingpin.Command("stats", "report graph statistics"))
+	remotesCmd  = withTopDirArg(kingpin.Command("remotes", "list remote bases and report ones pinned to different refs"))
+	rootsCmd    = withTopDirArg(kingpin.Command("roots", "list kustomizations no other kustomization references (entry points)"))
+	leavesCmd   = withTopDirArg(kingpin.Command("leaves", "list kustomizations that reference no other kustomization (pure bases)"))
+
+	conformanceCmd = withTopDirArg(kingpin.Command("conformance", "developer tool: render every format and check that each one has the same nodes and edges"))
+
 	loglevel = kingpin.Flag("loglevel", "set 'debug' for debug logging").Default("info").String()
+	output   = kingpin.Flag("output", "output file (default: stdout)").Short('o').String()
+	format   = kingpin.Flag("format", "output format: 'dot', 'tree', 'graphml', 'cytoscape', 'csv', 'backstage', 'plantuml' or 'd2'").Default("dot").Enum("dot", "tree", "graphml", "cytoscape", "csv", "backstage", "plantuml", "d2")
+	jobs     = kingpin.Flag("jobs", "number of kustomizations read in parallel").Short('j').Default(strconv.Itoa(runtime.NumCPU())).Int()
+
+	roots       = kingpin.Flag("root", "directory to discover kustomizations from (repeatable, default: topDir)").Strings()
+	rootOverlap = kingpin.Flag("root-overlap", "how to handle overlapping roots: 'merge', 'warn' or 'error'").Default("merge").Enum("merge", "warn", "error")
+	events      = kingpin.Flag("events", "stream scan events to stdout ('ndjson')").Default("none").Enum("none", "ndjson")
+
+	deemphasize          = kingpin.Flag("deemphasize", "render test/example/demo directories in a muted style").Default("true").Bool()
+	deemphasizePatterns  = kingpin.Flag("deemphasize-pattern", "additional regexp (matched against the relative path) for directories to de-emphasize").Strings()
+	collapseDeemphasized = kingpin.Flag("collapse-deemphasized", "collapse de-emphasized directories into one node per cluster").Bool()
+
+	detail       = kingpin.Flag("detail", "also render file-level references (generator sources) as nodes").Bool()
+	strict       = kingpin.Flag("strict", "exit with an error if any finding has severity error (a referenced file is not found, or --build-stats fails), except ones in --baseline").Bool()
+	reproducible = kingpin.Flag("reproducible", "byte-identical output for identical input (stable order, no timestamps, '/' separators, pinned layout seed)").Bool()
+	watch        = kingpin.Flag("watch", "regenerate the output file whenever a kustomization or referenced file changes").Bool()
 )
 
-type DirNode struct {
-	Kustomizations []string // kustomization.yaml のあるディレクトリ名
-	Children       map[string]*DirNode
-}
-type Edge struct {
-	Src string
-	Dst string
-}
+// 走査は pkg/graph で行い、結果をこのパッケージの変数に入れて各形式で出力する
+type (
+	DirNode       = graph.DirNode
+	Node          = graph.Node
+	Edge          = graph.Edge
+	EdgeType      = graph.EdgeType
+	HelmChartNode = graph.HelmChartNode
+)
+
+const (
+	EdgeTypeResource  = graph.EdgeTypeResource
+	EdgeTypeComponent = graph.EdgeTypeComponent
+	EdgeTypeHelmChart = graph.EdgeTypeHelmChart
+
+	EdgeTypeConfigMapGenerator = graph.EdgeTypeConfigMapGenerator
+	EdgeTypeSecretGenerator    = graph.EdgeTypeSecretGenerator
+	EdgeTypeReplacement        = graph.EdgeTypeReplacement
+	EdgeTypeExtracted          = graph.EdgeTypeExtracted
+)
 
 var rootDir = DirNode{Children: map[string]*DirNode{}}
+var nodes = map[string]*Node{}
 var edges = []Edge{}
 
+// watch モードで再生成する前に、前回の走査結果を捨てる
+func resetGraph() {
+	useGraph(graph.New())
+	notes = nil
+	foundPaths = nil
+	pathNodes = map[string]bool{}
+	pathEdges = map[Edge]bool{}
+	depthPlaceholders = map[string]int{}
+	edgeMultiplicity = map[Edge]int{}
+}
+
+// 並列に読むと追加順が実行ごとに変わるので、グラフを書き換えたあとは並べ直す
+func sortGraph() {
+	rootDir.Sort()
+	graph.SortEdges(edges)
+	graph.SortHelmCharts(helmCharts)
+}
+
+func relPath(path string) (string, error) {
+	rel, err := filepath.Rel(canonicalPath(*topDir), canonicalPath(path))
+	if err != nil {
+		return "", err
+	}
+	if *reproducible {
+		rel = filepath.ToSlash(rel)
+	}
+	return rel, nil
+}
+
+func withTopDirArg(cmd *kingpin.CmdClause) *kingpin.CmdClause {
+	cmd.Arg("topDir", "manifest top directory").Default(".").StringVar(topDir)
+	return cmd
+}
+
 func main() {
-	kingpin.Parse()
+	kingpin.CommandLine.Help = appHelp
+	if isKubectlPlugin() {
+		setupKubectlPlugin(kingpin.CommandLine)
+	}
+	command := kingpin.Parse()
 
 	var logger *zap.Logger
 	if *loglevel == "debug" {
@@ -45,201 +123,366 @@ func main() {
 	defer logger.Sync()
 	zap.ReplaceGlobals(logger)
 
-	fs := filesys.MakeFsOnDisk()
-	for _, dir := range findKustomizationDirs(fs, *topDir) {
-		err := readDir(fs, dir)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+	if *reproducible {
+		// 並列度が 1 ならイベントの順序も毎回同じになる
+		*jobs = 1
+	}
+
+	if command == pluginManifestCmd.FullCommand() {
+		if err := writeOutput(printPluginManifest); err != nil {
+			exitWithError(err)
 		}
+		return
 	}
 
-	fmt.Println("digraph G {")
-	printGraphNodes(&rootDir, "", 1)
-	printGraphEdges(&edges, 1)
-	fmt.Println("}")
-}
+	if command == modelDocsCmd.FullCommand() {
+		if err := writeOutput(printModelDocs); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
 
-func printGraphNodes(node *DirNode, dirName string, indentLevel int) {
-	indent := strings.Repeat(" ", 2*indentLevel)
-	nextIndent := strings.Repeat(" ", 2*(indentLevel+1))
+	if command == checkDirCmd.FullCommand() {
+		if err := runCheckDir(); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
 
-	for _, kustomization := range node.Kustomizations {
-		fmt.Printf(indent+"\"%s\"  [label=\"%s\"]\n", filepath.Join(dirName, kustomization), kustomization)
+	if command == fixturesGenerateCmd.FullCommand() {
+		if err := generateFixtures(); err != nil {
+			exitWithError(err)
+		}
+		return
 	}
 
-	for childName, childNode := range node.Children {
-		if childName == "." {
-			childName = "(root)"
+	if *inputArchive != "" {
+		if *watch {
+			exitWithError(fmt.Errorf("--watch cannot be used with --input"))
+		}
+		if err := useInputArchive(*inputArchive); err != nil {
+			exitWithError(err)
 		}
-		safeChildName := regexp.MustCompile("[\\-\\.()]").ReplaceAllString(childName, "_")
+	}
 
-		fmt.Println("")
-		fmt.Printf(indent+"subgraph cluster_%s {\n", safeChildName)
-		fmt.Printf(nextIndent+"label = \"%s\"\n", childName)
-		fmt.Println(nextIndent + "fillcolor=lightgray;")
-		fmt.Println(nextIndent + "style=filled;")
-		fmt.Println(nextIndent + "color=white;")
-		fmt.Println(nextIndent + "penwidth=3;")
-		fmt.Println(nextIndent + "node [style=filled,color=white];")
-		printGraphNodes(childNode, filepath.Join(dirName, childName), indentLevel+1)
-		fmt.Println(indent + "}")
+	if *deemphasize {
+		patterns := append(defaultDeemphasizePatterns, *deemphasizePatterns...)
+		if err := compileDeemphasizePatterns(patterns); err != nil {
+			exitWithError(err)
+		}
 	}
-}
 
-func printGraphEdges(edges *[]Edge, indentLevel int) {
-	indent := strings.Repeat(" ", 2*indentLevel)
+	if err := compileLabelTemplate(*labelTemplate); err != nil {
+		exitWithError(err)
+	}
 
-	for _, edge := range *edges {
-		fmt.Printf(indent+"\"%s\" -> \"%s\"\n", edge.Src, edge.Dst)
+	if err := parseSeverityOverrides(*severityOverrides); err != nil {
+		exitWithError(err)
+	}
+	if err := loadBaseline(*baselineFile); err != nil {
+		exitWithError(err)
 	}
-}
 
-func findKustomizationDirs(fs filesys.FileSystem, baseDir string) []string {
-	var kustomizationDirs []string
+	if *groupByEnv {
+		if err := compileEnvPattern(*envPattern); err != nil {
+			exitWithError(err)
+		}
+	}
 
-	fs.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	if *events == "ndjson" {
+		// イベントとグラフが stdout で混ざらないようにする
+		if *output == "" || *output == "-" {
+			exitWithError(fmt.Errorf("--events ndjson writes events to stdout; use --output for the graph"))
 		}
-		if !info.IsDir() && info.Name() == "kustomization.yaml" {
-			kustomizationDirs = append(kustomizationDirs, filepath.Dir(path))
+		enableEvents(os.Stdout)
+	}
+
+	if *watch {
+		if err := watchAndRun(command); err != nil {
+			exitWithError(err)
 		}
-		return nil
-	})
+		return
+	}
 
-	return kustomizationDirs
+	if err := run(command); err != nil {
+		exitWithError(err)
+	}
 }
 
-func readKustomizationFile(fs filesys.FileSystem, dir string) (*types.Kustomization, error) {
-	data, err := fs.ReadFile(filepath.Join(dir, "kustomization.yaml"))
-	if err != nil {
-		return nil, err
+// lint --report-format sarif などは stdout に出した結果をそのまま読ませるので、エラーは stderr に出す
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func run(command string) error {
+	if *showTimings {
+		start := time.Now()
+		defer func() { printTimings(os.Stderr, time.Since(start)) }()
 	}
 
-	var k types.Kustomization
-	if err := k.Unmarshal(data); err != nil {
-		return nil, err
+	if command == lintCmd.FullCommand() {
+		return runLint()
+	}
+
+	if command == mergeCmd.FullCommand() {
+		stopLoad := startPhase("load")
+		err := loadSnapshots(*mergeSnapshots)
+		stopLoad()
+		if err != nil {
+			return err
+		}
+	} else {
+		var stopProgress func()
+		if *showProgress {
+			stopProgress = startProgress(os.Stderr)
+		}
+		err := scanRoots(inputFileSystem(), *roots)
+		if stopProgress != nil {
+			stopProgress()
+		}
+		if err != nil {
+			return err
+		}
 	}
+	emitEvent(Event{Type: EventDone, Nodes: countNodes(&rootDir), Edges: len(edges)})
 
-	k.FixKustomization()
+	if err := writeFindings(*findingsOutput, collectFindings()); err != nil {
+		return err
+	}
 
-	return &k, nil
-}
+	if command == scanCmd.FullCommand() {
+		return writeOutput(printSnapshot)
+	}
 
-func readDir(fs filesys.FileSystem, dir string) error {
-	logger := zap.S()
-	logger.Debugf("----- %s -----", dir)
+	if *strict {
+		if n := countFailingFindings(collectFindings()); n > 0 {
+			return fmt.Errorf("%d findings with severity error (referenced paths not found or build failures)", n)
+		}
+	}
 
-	kustomization, err := readKustomizationFile(fs, dir)
-	if err != nil {
+	if err := loadNotes(*notesFile); err != nil {
 		return err
 	}
-	// pp.Print(kustomization)
 
-	rel, err := filepath.Rel(*topDir, dir)
-	if err != nil {
-		return err
+	// 統計や一覧は木全体から数えるので、折りたたむのは描画するときだけ
+	if command == graphCmd.FullCommand() || command == mergeCmd.FullCommand() {
+		limitDepth()
+	}
+	if *transitiveReduction {
+		reduceTransitiveEdges()
 	}
 
-	err = appendToDirTree(rel)
-	if err != nil {
+	if *printPaths && *pathBetween == "" {
+		return fmt.Errorf("--print-paths requires --path-between")
+	}
+	if err := findPathsBetween(*pathBetween); err != nil {
 		return err
 	}
 
-	var nextDirs []string
-
-	for _, v := range kustomization.Resources {
-		logger.Debugf("- (resource) %s", v)
-		nextPath := filepath.Join(dir, v)
+	if command == tuiCmd.FullCommand() {
+		return runTUI()
+	}
 
-		if !fs.Exists(nextPath) {
-			logger.Debugf("/* %s is not found */", nextPath)
-		} else if fs.IsDir(nextPath) {
-			nextDirs = append(nextDirs, nextPath)
+	print := printGraph
+	switch {
+	case command == featuresCmd.FullCommand():
+		print = printFeatureReport
+	case command == statsCmd.FullCommand():
+		print = printStats
+	case command == remotesCmd.FullCommand():
+		print = printRemoteReport
+	case command == rootsCmd.FullCommand():
+		print = printRoots
+	case command == leavesCmd.FullCommand():
+		print = printLeaves
+	case command == conformanceCmd.FullCommand():
+		print = printConformance
+	case *printPaths:
+		print = printFoundPaths
+	case *format == "tree":
+		print = printTree
+	case *format == "graphml":
+		print = printGraphML
+	case *format == "cytoscape":
+		print = printCytoscapeJSON
+	case *format == "csv":
+		print = printEdgesCSV
+	case *format == "backstage":
+		print = printBackstageEntities
+	case *format == "plantuml":
+		print = printPlantUML
+	case *format == "d2":
+		print = printD2
+	}
+	// 統計などには含めたまま、描画するグラフからだけ除く
+	if *hideIsolated && (command == graphCmd.FullCommand() || command == mergeCmd.FullCommand()) {
+		hideIsolatedNodes()
+	}
+	if *mergeLeaves && (command == graphCmd.FullCommand() || command == mergeCmd.FullCommand()) {
+		mergeEquivalentLeaves()
+	}
+	defer startPhase("emit")()
+	if *splitByRoot {
+		if command != graphCmd.FullCommand() && command != mergeCmd.FullCommand() {
+			return fmt.Errorf("--split-by-root can only be used with 'graph' and 'merge'")
 		}
+		return writeSplitByRoot(print)
+	}
+	if useGraphviz() {
+		return runGraphviz(print)
 	}
-	for _, v := range kustomization.Components {
-		logger.Debugf("- (component) %s", v)
-		nextPath := filepath.Join(dir, v)
+	return writeOutput(print)
+}
 
-		if !fs.Exists(nextPath) {
-			logger.Warnf("%s is not found", nextPath)
-		} else if fs.IsDir(nextPath) {
-			nextDirs = append(nextDirs, nextPath)
-		}
+func writeOutput(print func(w io.Writer) error) error {
+	if *output == "" || *output == "-" {
+		return print(os.Stdout)
 	}
 
-	// 以下はファイル単位なので、いったん表示には使わない。存在チェックのみ
-	// 詳細モードとかあってもいいかも
-	for _, v := range kustomization.Patches {
-		logger.Debugf("- (patch) %s", v.Path)
-		nextPath := filepath.Join(dir, v.Path)
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-		if !fs.Exists(nextPath) {
-			logger.Warnf("%s is not found", nextPath)
-		}
+	return print(f)
+}
+
+func countNodes(node *DirNode) int {
+	n := len(node.Kustomizations)
+	for _, child := range node.Children {
+		n += countNodes(child)
 	}
-	for _, v := range kustomization.Replacements {
-		logger.Debugf("- (replacement) %s", v.Path)
-		nextPath := filepath.Join(dir, v.Path)
+	return n
+}
 
-		if !fs.Exists(nextPath) {
-			logger.Warnf("%s is not found", nextPath)
-		}
+func printGraph(w io.Writer) error {
+	fmt.Fprintln(w, "digraph G {")
+	if *reproducible {
+		// neato/fdp などで使われる初期配置の乱数シードを固定する
+		fmt.Fprintln(w, "  start=1;")
+	}
+	countSharedReferrers()
+	if *tooltips {
+		collectNodeFindings()
+	}
+	if *groupByEnv {
+		printEnvGroupedNodes(w, 1)
+	} else {
+		printGraphNodes(w, &rootDir, "", 1)
 	}
-	for _, v := range kustomization.Transformers {
-		logger.Debugf("- (transformer) %s", v)
-		nextPath := filepath.Join(dir, v)
+	printHelmChartNodes(w, 1)
+	printDepthPlaceholders(w, 1)
+	printGraphEdges(w, &edges, 1)
+	printPathHighlights(w, 1)
+	printNotes(w, 1)
+	fmt.Fprintln(w, "}")
+	return nil
+}
 
-		if !fs.Exists(nextPath) {
-			logger.Warnf("%s is not found", nextPath)
+func printGraphNodes(w io.Writer, node *DirNode, dirName string, indentLevel int) {
+	indent := strings.Repeat(" ", 2*indentLevel)
+	nextIndent := strings.Repeat(" ", 2*(indentLevel+1))
+
+	collapsed := 0
+	for _, kustomization := range node.Kustomizations {
+		path := filepath.Join(dirName, kustomization)
+		if *collapseDeemphasized && isDeemphasized(path) {
+			collapsed++
+			continue
 		}
+		printKustomizationNode(w, indent, path, kustomization)
 	}
-	for _, v := range kustomization.Configurations {
-		logger.Debugf("- (configuration) %s", v)
-		nextPath := filepath.Join(dir, v)
+	if collapsed > 0 {
+		printCollapsedNode(w, indent, filepath.Join(dirName, collapsedNodeName), collapsed)
+	}
+	printFileNodes(w, node, dirName, indentLevel)
 
-		if !fs.Exists(nextPath) {
-			logger.Warnf("%s is not found", nextPath)
-		}
+	var childNames []string
+	for childName := range node.Children {
+		childNames = append(childNames, childName)
 	}
+	sort.Strings(childNames)
 
-	for _, nextDir := range nextDirs {
-		nextDir, err := filepath.Rel(*topDir, nextDir)
-		if err != nil {
-			return err
+	for _, childName := range childNames {
+		childNode := node.Children[childName]
+		childPath := filepath.Join(dirName, childName)
+		label := childName
+		if childName == "." {
+			label = "(root)"
 		}
-		logger.Debugf("[edge] \"%s\" -> \"%s\"", rel, nextDir)
 
-		newEdge := Edge{Src: rel, Dst: nextDir}
-		if !util.Contains(edges, newEdge) {
-			edges = append(edges, newEdge)
-		}
+		// クラスタ ID はフルパスから作るので、別の階層にある同名ディレクトリとも衝突しない
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, indent+"subgraph %s {\n", dotID("cluster_dir:"+childPath))
+		printClusterStyle(w, nextIndent, label)
+		printGraphNodes(w, childNode, childPath, indentLevel+1)
+		fmt.Fprintln(w, indent+"}")
 	}
+}
+
+func printClusterStyle(w io.Writer, indent string, label string) {
+	fmt.Fprintf(w, indent+"label = \"%s\"\n", escapeDOT(label, `\n`))
+	fmt.Fprintln(w, indent+"fillcolor=lightgray;")
+	fmt.Fprintln(w, indent+"style=filled;")
+	fmt.Fprintln(w, indent+"color=white;")
+	fmt.Fprintln(w, indent+"penwidth=3;")
+	fmt.Fprintln(w, indent+"node [style=filled,color=white];")
+}
 
-	for _, nextDir := range nextDirs {
-		readDir(fs, nextDir)
+// name はラベルの既定値 (ラベルテンプレートがなければそのまま表示する)
+func printKustomizationNode(w io.Writer, indent string, path string, name string) {
+	label := escapeDOT(nodeLabel(path, name)+sharedLabel(path)+buildStatsLabel(path), `\n`)
+	if isDeemphasized(path) {
+		fmt.Fprintf(w, indent+"%s  [label=\"%s\", %s%s%s%s%s]\n", dotID(path), label, mutedNodeAttrs, sharedAttrs(path), buildStatsAttrs(path), nodeTooltipAttrs(path), linkAttrs(path))
+	} else {
+		fmt.Fprintf(w, indent+"%s  [label=\"%s\"%s%s%s%s]\n", dotID(path), label, sharedAttrs(path), buildStatsAttrs(path), nodeTooltipAttrs(path), linkAttrs(path))
 	}
+}
 
-	return nil
+func printCollapsedNode(w io.Writer, indent string, id string, count int) {
+	fmt.Fprintf(w, indent+"%s  [label=\"%d test/example dirs\", %s]\n", dotID(id), count, mutedNodeAttrs)
 }
 
-func appendToDirTree(dir string) error {
-	parentDirs := strings.Split(filepath.Dir(strings.Trim(dir, "/")), "/")
+func printGraphEdges(w io.Writer, edges *[]Edge, indentLevel int) {
+	indent := strings.Repeat(" ", 2*indentLevel)
 
-	d := &rootDir
-	for _, parentDir := range parentDirs {
-		if _, ok := d.Children[parentDir]; !ok {
-			d.Children[parentDir] = &DirNode{Children: map[string]*DirNode{}}
+	printed := map[Edge]bool{}
+	for _, edge := range *edges {
+		e := Edge{Src: displayNodeID(edge.Src), Dst: displayNodeID(edge.Dst)}
+		if e.Src == e.Dst || printed[e] {
+			continue
 		}
-		d = d.Children[parentDir]
-	}
+		printed[e] = true
 
-	basename := filepath.Base(dir)
-	if !slices.Contains(d.Kustomizations, basename) {
-		d.Kustomizations = append(d.Kustomizations, basename)
+		attrs := edgeAttrs(edge)
+		if label := edgeLabelAttrs(edge); label != "" && attrs != "" {
+			attrs += ", " + label
+		} else if label != "" {
+			attrs = label
+		}
+		if attrs != "" {
+			fmt.Fprintf(w, indent+"%s -> %s  [%s]\n", dotID(e.Src), dotID(e.Dst), attrs)
+		} else {
+			fmt.Fprintf(w, indent+"%s -> %s\n", dotID(e.Src), dotID(e.Dst))
+		}
 	}
+}
 
-	return nil
+func edgeAttrs(edge Edge) string {
+	switch {
+	case isPathEdge(edge):
+		return pathEdgeAttrs
+	case isDeemphasized(edge.Src) || isDeemphasized(edge.Dst):
+		return mutedEdgeAttrs
+	case edge.Type == EdgeTypeHelmChart:
+		return helmChartEdgeAttrs
+	case edge.Type == EdgeTypeConfigMapGenerator || edge.Type == EdgeTypeSecretGenerator || edge.Type == EdgeTypeExtracted:
+		return fileEdgeAttrs
+	case edge.Type == EdgeTypeReplacement:
+		return replacementEdgeAttrs
+	default:
+		return ""
+	}
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/alecthomas/kingpin"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var mergeLeaves = kingpin.Flag("merge-leaves", "merge unreferenced kustomizations with the same name in sibling directories (e.g. dev/app, prod/app) into one '*/app' node").Bool()
+
+// 同じ参照元・参照先の組にまとめられたエッジの数
+var edgeMultiplicity = map[Edge]int{}
+
+func mergedLeafID(path string) string {
+	return filepath.Join(filepath.Dir(filepath.Dir(path)), "*", filepath.Base(path))
+}
+
+func mergeEquivalentLeaves() {
+	referenced := map[string]bool{}
+	for _, edge := range edges {
+		referenced[edge.Dst] = true
+	}
+
+	groups := map[string][]string{}
+	for path := range nodes {
+		if !referenced[path] && filepath.Dir(path) != "." {
+			groups[mergedLeafID(path)] = append(groups[mergedLeafID(path)], path)
+		}
+	}
+
+	merged := map[string]string{}
+	var removed []string
+	var ids []string
+	for id, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		sort.Strings(members)
+		for _, member := range members {
+			merged[member] = id
+		}
+		removed = append(removed, members...)
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	sort.Strings(ids)
+	for _, id := range ids {
+		node := *nodes[groups[id][0]]
+		node.Path = id
+		nodes[id] = &node
+
+		// 環境のディレクトリをまたぐので、その親のクラスタに "*/app" として置く
+		d := rootDir.Parent(filepath.Dir(id))
+		d.Kustomizations = append(d.Kustomizations, filepath.Join("*", filepath.Base(id)))
+	}
+	removeNodes(removed)
+
+	var kept []Edge
+	for _, edge := range edges {
+		id, ok := merged[edge.Src]
+		if !ok {
+			kept = append(kept, edge)
+			continue
+		}
+		original := Edge{Src: edge.Src, Dst: edge.Dst}
+		edge.Src = id
+		key := Edge{Src: edge.Src, Dst: edge.Dst}
+		edgeMultiplicity[key]++
+		for _, entry := range edgeEntries[original] {
+			edgeEntries[key] = graph.AppendEdgeEntry(edgeEntries[key], entry)
+		}
+		if edgeMultiplicity[key] == 1 {
+			kept = append(kept, edge)
+		}
+	}
+	edges = kept
+	sortGraph()
+}
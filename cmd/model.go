@@ -0,0 +1,106 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+type NodeType string
+
+const (
+	NodeTypeKustomization NodeType = "kustomization"
+	NodeTypeComponent     NodeType = "component"
+	NodeTypeHelmChart     NodeType = "helmChart"
+	NodeTypeFile          NodeType = "file"
+	NodeTypeUnknown       NodeType = "unknown"     // 参照されているが kustomization として読めなかったもの
+	NodeTypePlaceholder   NodeType = "placeholder" // --max-depth で折りたたんだ部分
+)
+
+// DOT 以外の出力形式で使う、クラスタ構造を持たないノード
+type FlatNode struct {
+	ID      string
+	Label   string
+	Type    NodeType
+	Cluster string // 親ディレクトリ
+}
+
+func flatNodes() []FlatNode {
+	var flat []FlatNode
+	known := map[string]bool{}
+	add := func(n FlatNode) {
+		if !known[n.ID] {
+			known[n.ID] = true
+			flat = append(flat, n)
+		}
+	}
+
+	for path, node := range nodes {
+		nodeType := NodeTypeKustomization
+		if node.Kind == types.ComponentKind {
+			nodeType = NodeTypeComponent
+		}
+		add(FlatNode{ID: path, Label: nodeLabel(path, filepath.Base(path)), Type: nodeType, Cluster: filepath.Dir(path)})
+	}
+	for _, chart := range helmCharts {
+		add(FlatNode{ID: chart.ID(), Label: chart.Name, Type: NodeTypeHelmChart})
+	}
+	for _, edge := range edges {
+		dstType := NodeTypeUnknown
+		if edge.Type == EdgeTypeConfigMapGenerator || edge.Type == EdgeTypeSecretGenerator || edge.Type == EdgeTypeReplacement || edge.Type == EdgeTypeExtracted {
+			dstType = NodeTypeFile
+		}
+		add(FlatNode{ID: edge.Src, Label: filepath.Base(edge.Src), Type: NodeTypeUnknown, Cluster: filepath.Dir(edge.Src)})
+		if _, ok := depthPlaceholders[edge.Dst]; ok {
+			add(FlatNode{ID: edge.Dst, Label: depthPlaceholderLabel(edge.Dst), Type: NodeTypePlaceholder, Cluster: edge.Src})
+		}
+		add(FlatNode{ID: edge.Dst, Label: filepath.Base(edge.Dst), Type: dstType, Cluster: filepath.Dir(edge.Dst)})
+	}
+
+	sort.Slice(flat, func(i, j int) bool {
+		return flat[i].ID < flat[j].ID
+	})
+	return flat
+}
+
+// PlantUML や D2 のように入れ子のグループを持つ出力形式で使う、FlatNode.Cluster の木
+type ClusterTree struct {
+	Name     string
+	Nodes    []FlatNode
+	Children map[string]*ClusterTree
+}
+
+func (t *ClusterTree) ChildNames() []string {
+	var names []string
+	for name := range t.Children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func clusterTree(flat []FlatNode) *ClusterTree {
+	root := &ClusterTree{Children: map[string]*ClusterTree{}}
+	for _, node := range flat {
+		cluster := node.Cluster
+		if node.Type == NodeTypePlaceholder {
+			// DOT と同じくクラスタの外に置く
+			cluster = ""
+		}
+
+		t := root
+		for _, name := range strings.Split(filepath.ToSlash(cluster), "/") {
+			if name == "" || name == "." {
+				continue
+			}
+			if t.Children[name] == nil {
+				t.Children[name] = &ClusterTree{Name: name, Children: map[string]*ClusterTree{}}
+			}
+			t = t.Children[name]
+		}
+		t.Nodes = append(t.Nodes, node)
+	}
+	return root
+}
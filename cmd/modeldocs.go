@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var (
+	modelCmd     = kingpin.Command("model", "reference of the graph model")
+	modelDocsCmd = modelCmd.Command("docs", "print a Markdown reference of the graph model and its JSON form, generated from the struct tags")
+)
+
+// model docs に載せる型。ここから参照される struct もたどって載せる
+var modelDocTypes = []reflect.Type{
+	reflect.TypeOf(Snapshot{}),
+	reflect.TypeOf(Event{}),
+}
+
+// 型の定義から作るので、フィールドを足せばそのまま載る (doc タグがないものは空欄になる)
+func printModelDocs(w io.Writer) error {
+	fmt.Fprintln(w, "# Graph model")
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "`scan` writes the graph as one JSON object of type [Snapshot](#snapshot) (version %d), and `merge` reads it back.\n", snapshotVersion)
+	fmt.Fprintln(w, "`--events ndjson` writes one [Event](#event) per line.")
+	fmt.Fprintln(w, "Field names are the JSON names. Only JSON is defined; there is no Protocol Buffers schema because nothing in the tool reads or writes protobuf.")
+	fmt.Fprintln(w, "Paths are relative to topDir.")
+
+	queue := append([]reflect.Type{}, modelDocTypes...)
+	seen := map[reflect.Type]bool{}
+	for _, t := range queue {
+		seen[t] = true
+	}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "## %s\n", t.Name())
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "| Field | Type | Description | Example |")
+		fmt.Fprintln(w, "|---|---|---|---|")
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			description := f.Tag.Get("doc")
+			if strings.Contains(opts, "omitempty") {
+				description += " (optional)"
+			}
+			example := ""
+			if e := f.Tag.Get("example"); e != "" {
+				example = "`" + e + "`"
+			}
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", name, modelTypeName(f.Type), escapeMarkdownCell(description), escapeMarkdownCell(example))
+
+			for _, s := range modelStructTypes(f.Type) {
+				if !seen[s] {
+					seen[s] = true
+					queue = append(queue, s)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func modelTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return modelTypeName(t.Elem())
+	case reflect.Slice:
+		return "array of " + modelTypeName(t.Elem())
+	case reflect.Map:
+		return "object of " + modelTypeName(t.Elem())
+	case reflect.Struct:
+		return fmt.Sprintf("[%s](#%s)", t.Name(), strings.ToLower(t.Name()))
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// フィールドの型から参照される struct (配列やマップの要素も含む)
+func modelStructTypes(t reflect.Type) []reflect.Type {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		return modelStructTypes(t.Elem())
+	case reflect.Struct:
+		return []reflect.Type{t}
+	default:
+		return nil
+	}
+}
+
+func escapeMarkdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// model docs が空欄にならないように、載せる型のフィールドにはすべて doc タグを書く
+func TestModelDocsDescribeEveryField(t *testing.T) {
+	queue := append([]reflect.Type{}, modelDocTypes...)
+	seen := map[reflect.Type]bool{}
+	for _, typ := range queue {
+		seen[typ] = true
+	}
+	for len(queue) > 0 {
+		typ := queue[0]
+		queue = queue[1:]
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if f.Tag.Get("json") == "" {
+				t.Errorf("%s.%s has no json tag", typ.Name(), f.Name)
+			}
+			if f.Tag.Get("doc") == "" {
+				t.Errorf("%s.%s has no doc tag", typ.Name(), f.Name)
+			}
+			for _, s := range modelStructTypes(f.Type) {
+				if !seen[s] {
+					seen[s] = true
+					queue = append(queue, s)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	notesFile  = kingpin.Flag("notes", "YAML file mapping node paths (relative to topDir) to freeform notes rendered in DOT output").String()
+	notesStyle = kingpin.Flag("notes-style", "how to render notes: 'note' (attached note nodes) or 'tooltip'").Default("note").Enum("note", "tooltip")
+)
+
+const noteNodeAttrs = `shape=note, style=filled, fillcolor=lightyellow, color=gray50, fontsize=10`
+
+var notes map[string]string
+
+func loadNotes(path string) error {
+	notes = nil
+	if path == "" {
+		return nil
+	}
+	recordReference(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	notes = map[string]string{}
+	for key, text := range raw {
+		// helm チャートは ID (helm:repo/name@version) のまま書く
+		if !strings.HasPrefix(key, "helm:") {
+			key = filepath.Clean(filepath.FromSlash(key))
+		}
+		notes[key] = strings.TrimRight(text, "\n")
+	}
+	return nil
+}
+
+func printNotes(w io.Writer, indentLevel int) {
+	if len(notes) == 0 {
+		return
+	}
+	indent := strings.Repeat(" ", 2*indentLevel)
+
+	known := map[string]bool{}
+	for _, node := range flatNodes() {
+		known[node.ID] = true
+	}
+
+	var paths []string
+	for path := range notes {
+		if !known[path] {
+			zap.S().Warnf("note for %s does not match any node", path)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Fprintln(w, "")
+	for _, path := range paths {
+		id := displayNodeID(path)
+		if *notesStyle == "tooltip" {
+			if id == path && hasNodeTooltip(path) {
+				continue // --tooltips のツールチップに含めてある
+			}
+			fmt.Fprintf(w, indent+"%s  [tooltip=\"%s\"]\n", dotID(id), escapeDOT(notes[path], `\n`))
+			continue
+		}
+		fmt.Fprintf(w, indent+"%s  [label=\"%s\", %s]\n", dotID("note:"+path), escapeDOT(notes[path], `\l`)+`\l`, noteNodeAttrs)
+		fmt.Fprintf(w, indent+"%s -> %s  [style=dotted, arrowhead=none, color=gray50]\n", dotID("note:"+path), dotID(id))
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"go.uber.org/zap"
+)
+
+var (
+	pathBetween = kingpin.Flag("path-between", "highlight all dependency paths from one node to another, as 'A->B'").String()
+	printPaths  = kingpin.Flag("print-paths", "print the paths found by --path-between instead of the graph").Bool()
+	maxPaths    = kingpin.Flag("max-paths", "maximum number of paths --print-paths prints; the number of paths can grow exponentially").Default("1000").Int()
+)
+
+const (
+	pathNodeAttrs = `color=red, fillcolor=mistyrose, penwidth=2`
+	pathEdgeAttrs = `color=red, penwidth=2`
+)
+
+// --path-between で見つかった経路と、その上にあるノード・エッジ
+var (
+	foundPaths [][]string
+	pathNodes  = map[string]bool{}
+	pathEdges  = map[Edge]bool{} // Type は無視して Src, Dst だけで引く
+)
+
+func findPathsBetween(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	from, to, ok := strings.Cut(spec, "->")
+	if !ok {
+		return fmt.Errorf("invalid --path-between %q (expected 'A->B')", spec)
+	}
+	from, to = filepath.Clean(strings.TrimSpace(from)), filepath.Clean(strings.TrimSpace(to))
+
+	known := map[string]bool{}
+	for _, node := range flatNodes() {
+		known[node.ID] = true
+	}
+	for _, id := range []string{from, to} {
+		if !known[id] {
+			return fmt.Errorf("%s is not a node in the graph", id)
+		}
+	}
+
+	deps := map[string][]string{}
+	referrers := map[string][]string{}
+	for _, edge := range edges {
+		deps[edge.Src] = append(deps[edge.Src], edge.Dst)
+		referrers[edge.Dst] = append(referrers[edge.Dst], edge.Src)
+	}
+	for _, v := range deps {
+		sort.Strings(v)
+	}
+
+	// 経路上のノードは from から到達でき、かつ to に到達できるもの。経路を列挙すると指数的に増えるので、強調表示はこれだけで決める
+	reachable := reachableVia(from, deps)
+	reaches := reachableVia(to, referrers)
+	for id := range reachable {
+		if reaches[id] {
+			pathNodes[id] = true
+		}
+	}
+	for _, edge := range edges {
+		if pathNodes[edge.Src] && pathNodes[edge.Dst] {
+			pathEdges[Edge{Src: edge.Src, Dst: edge.Dst}] = true
+		}
+	}
+	if len(pathNodes) == 0 {
+		zap.S().Warnf("no dependency path from %s to %s", from, to)
+		return nil
+	}
+
+	if *printPaths {
+		if truncated := enumeratePaths(from, to, deps, reaches, *maxPaths); truncated {
+			zap.S().Warnf("printing only the first %d paths from %s to %s; raise --max-paths to print more", *maxPaths, from, to)
+		}
+	}
+	return nil
+}
+
+// start から next のエッジをたどって到達できるノード (start を含む)
+func reachableVia(start string, next map[string][]string) map[string]bool {
+	reachable := map[string]bool{start: true}
+	queue := []string{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, n := range next[id] {
+			if !reachable[n] {
+				reachable[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return reachable
+}
+
+// from から to への経路を limit 件まで foundPaths に入れる。打ち切ったら true
+func enumeratePaths(from, to string, deps map[string][]string, reaches map[string]bool, limit int) bool {
+	truncated := false
+	onPath := map[string]bool{}
+	var walk func(id string, path []string)
+	walk = func(id string, path []string) {
+		if truncated {
+			return
+		}
+		path = append(path, id)
+		if id == to {
+			if len(foundPaths) >= limit {
+				truncated = true
+				return
+			}
+			foundPaths = append(foundPaths, append([]string(nil), path...))
+			return
+		}
+		onPath[id] = true
+		for i, next := range deps[id] {
+			if i > 0 && deps[id][i-1] == next {
+				continue // 種類違いの同じエッジ
+			}
+			if reaches[next] && !onPath[next] {
+				walk(next, path)
+			}
+		}
+		delete(onPath, id)
+	}
+	walk(from, nil)
+	return truncated
+}
+
+func isPathEdge(edge Edge) bool {
+	return pathEdges[Edge{Src: edge.Src, Dst: edge.Dst}]
+}
+
+// ノードはクラスタ内で宣言済みなので、属性だけ後から上書きする
+func printPathHighlights(w io.Writer, indentLevel int) {
+	if len(pathNodes) == 0 {
+		return
+	}
+	indent := strings.Repeat(" ", 2*indentLevel)
+
+	var ids []string
+	for id := range pathNodes {
+		ids = append(ids, displayNodeID(id))
+	}
+	sort.Strings(ids)
+
+	fmt.Fprintln(w, "")
+	for i, id := range ids {
+		if i > 0 && ids[i-1] == id {
+			continue
+		}
+		fmt.Fprintf(w, indent+"%s  [%s]\n", dotID(id), pathNodeAttrs)
+	}
+}
+
+func printFoundPaths(w io.Writer) error {
+	for _, path := range foundPaths {
+		fmt.Fprintln(w, strings.Join(path, " -> "))
+	}
+	return nil
+}
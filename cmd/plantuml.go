@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var plantUMLElements = map[NodeType]string{
+	NodeTypeKustomization: "rectangle",
+	NodeTypeComponent:     "component",
+	NodeTypeHelmChart:     "database",
+	NodeTypeFile:          "file",
+	NodeTypeUnknown:       "card",
+	NodeTypePlaceholder:   "card",
+}
+
+// パスはそのままでは識別子に使えないので、n0, n1, ... の別名を振る
+func printPlantUML(w io.Writer) error {
+	flat := flatNodes()
+	aliases := map[string]string{}
+	for i, node := range flat {
+		aliases[node.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	fmt.Fprintln(w, "@startuml")
+	printPlantUMLCluster(w, clusterTree(flat), aliases, 0)
+	for _, edge := range edges {
+		arrow := "-->"
+		if edge.Type != EdgeTypeResource && edge.Type != EdgeTypeComponent {
+			arrow = "..>"
+		}
+		fmt.Fprintf(w, "%s %s %s\n", aliases[edge.Src], arrow, aliases[edge.Dst])
+	}
+	fmt.Fprintln(w, "@enduml")
+	return nil
+}
+
+func printPlantUMLCluster(w io.Writer, t *ClusterTree, aliases map[string]string, indentLevel int) {
+	indent := strings.Repeat(" ", 2*indentLevel)
+
+	for _, node := range t.Nodes {
+		fmt.Fprintf(w, indent+"%s \"%s\" as %s\n", plantUMLElements[node.Type], escapePlantUML(node.Label), aliases[node.ID])
+	}
+	for _, name := range t.ChildNames() {
+		fmt.Fprintf(w, indent+"package \"%s\" {\n", escapePlantUML(name))
+		printPlantUMLCluster(w, t.Children[name], aliases, indentLevel+1)
+		fmt.Fprintln(w, indent+"}")
+	}
+}
+
+// PlantUML の文字列にはエスケープがないので、引用符は似た文字に置き換える
+func escapePlantUML(s string) string {
+	return strings.NewReplacer(`"`, `'`, "\n", `\n`).Replace(s)
+}
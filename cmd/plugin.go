@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	appHelp       = "Render the dependency graph of kustomizations under a directory."
+	pluginName    = "kustomize-graph"
+	pluginBinary  = "kubectl-kustomize_graph"
+	pluginEnvFlag = "KUBECTL_PLUGINS_LOCAL_FLAG_"
+)
+
+var (
+	pluginCmd         = kingpin.Command("plugin", "kubectl/krew plugin packaging")
+	pluginManifestCmd = pluginCmd.Command("manifest", "generate a krew plugin manifest")
+	manifestVersion   = pluginManifestCmd.Flag("version", "release version (e.g. v0.1.0)").Required().String()
+	manifestURLPrefix = pluginManifestCmd.Flag("url-prefix", "URL the release archives are downloaded from").Required().String()
+	manifestArchives  = pluginManifestCmd.Flag("archive", "release archive as 'os/arch=path' (repeatable); sha256 is computed from the file").Required().Strings()
+	manifestHomepage  = pluginManifestCmd.Flag("homepage", "plugin homepage").Default("https://github.com/ks-yuzu/kustomize-graphing").String()
+)
+
+// kubectl-kustomize_graph として置かれていれば `kubectl kustomize-graph` として振る舞う
+func isKubectlPlugin() bool {
+	return strings.HasPrefix(filepath.Base(os.Args[0]), "kubectl-")
+}
+
+func setupKubectlPlugin(app *kingpin.Application) {
+	app.Name = "kubectl " + pluginName
+
+	// 旧来の kubectl plugin では、フラグが KUBECTL_PLUGINS_LOCAL_FLAG_<NAME> で渡される
+	for _, flag := range app.Model().Flags {
+		setPluginEnvar(app.GetFlag(flag.Name))
+	}
+	for _, cmd := range app.Model().Commands {
+		setPluginEnvarsForCommand(app.GetCommand(cmd.Name))
+	}
+}
+
+func setPluginEnvarsForCommand(cmd *kingpin.CmdClause) {
+	for _, flag := range cmd.Model().Flags {
+		setPluginEnvar(cmd.GetFlag(flag.Name))
+	}
+	for _, sub := range cmd.Model().Commands {
+		setPluginEnvarsForCommand(cmd.GetCommand(sub.Name))
+	}
+}
+
+func setPluginEnvar(flag *kingpin.FlagClause) {
+	if flag == nil {
+		return
+	}
+	name := flag.Model().Name
+	if name == "help" || strings.HasPrefix(name, "help-") || strings.HasPrefix(name, "completion-") {
+		return
+	}
+	flag.Envar(pluginEnvFlag + strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+}
+
+type krewPlugin struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec krewPluginSpec `yaml:"spec"`
+}
+
+type krewPluginSpec struct {
+	Version          string         `yaml:"version"`
+	Homepage         string         `yaml:"homepage"`
+	ShortDescription string         `yaml:"shortDescription"`
+	Description      string         `yaml:"description"`
+	Platforms        []krewPlatform `yaml:"platforms"`
+}
+
+type krewPlatform struct {
+	Selector struct {
+		MatchLabels map[string]string `yaml:"matchLabels"`
+	} `yaml:"selector"`
+	URI    string `yaml:"uri"`
+	Sha256 string `yaml:"sha256"`
+	Bin    string `yaml:"bin"`
+}
+
+// --format を足したときに説明が古くならないように、outputExtensions から作る
+func outputFormatList() string {
+	var formats []string
+	for format := range outputExtensions {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return strings.Join(formats[:len(formats)-1], ", ") + " or " + formats[len(formats)-1]
+}
+
+func printPluginManifest(w io.Writer) error {
+	plugin := krewPlugin{APIVersion: "krew.googlecontainertools.github.com/v1alpha2", Kind: "Plugin"}
+	plugin.Metadata.Name = pluginName
+	plugin.Spec = krewPluginSpec{
+		Version:          *manifestVersion,
+		Homepage:         *manifestHomepage,
+		ShortDescription: "Render kustomization dependency graphs",
+		Description:      appHelp + "\nOutputs " + outputFormatList() + ".\n",
+	}
+
+	for _, archive := range *manifestArchives {
+		platform, path, ok := strings.Cut(archive, "=")
+		goos, goarch, ok2 := strings.Cut(platform, "/")
+		if !ok || !ok2 {
+			return fmt.Errorf("invalid archive %q (expected 'os/arch=path')", archive)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+
+		p := krewPlatform{
+			URI:    strings.TrimSuffix(*manifestURLPrefix, "/") + "/" + filepath.Base(path),
+			Sha256: hex.EncodeToString(sum[:]),
+			Bin:    pluginBinary,
+		}
+		if goos == "windows" {
+			p.Bin += ".exe"
+		}
+		p.Selector.MatchLabels = map[string]string{"os": goos, "arch": goarch}
+		plugin.Spec.Platforms = append(plugin.Spec.Platforms, p)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(plugin)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var (
+	showProgress = kingpin.Flag("progress", "periodically show scan progress (directories discovered, kustomizations parsed, edges found) on stderr").Bool()
+	showTimings  = kingpin.Flag("timings", "print how long each phase (walk, parse, build, emit) took on stderr").Bool()
+)
+
+const progressInterval = 500 * time.Millisecond
+
+// 走査のイベントから数える (--events を使わないときも数える)
+var progressCounts [4]int64
+
+const (
+	progressDiscovered = iota
+	progressParsed
+	progressEdges
+	progressIssues
+)
+
+func countProgress(t EventType) {
+	switch t {
+	case EventDiscover:
+		atomic.AddInt64(&progressCounts[progressDiscovered], 1)
+	case EventParse:
+		atomic.AddInt64(&progressCounts[progressParsed], 1)
+	case EventEdge:
+		atomic.AddInt64(&progressCounts[progressEdges], 1)
+	case EventIssue:
+		atomic.AddInt64(&progressCounts[progressIssues], 1)
+	}
+}
+
+func progressLine() string {
+	return fmt.Sprintf("discovered %d, parsed %d, edges %d, issues %d",
+		atomic.LoadInt64(&progressCounts[progressDiscovered]),
+		atomic.LoadInt64(&progressCounts[progressParsed]),
+		atomic.LoadInt64(&progressCounts[progressEdges]),
+		atomic.LoadInt64(&progressCounts[progressIssues]))
+}
+
+// 端末なら同じ行を書き換え、そうでなければ 1 行ずつ出す。返す関数で止めて最後の状態を出す
+func startProgress(w *os.File) func() {
+	for i := range progressCounts {
+		atomic.StoreInt64(&progressCounts[i], 0)
+	}
+	info, err := w.Stat()
+	terminal := err == nil && info.Mode()&os.ModeCharDevice != 0
+	print := func() {
+		if terminal {
+			fmt.Fprintf(w, "\r\033[K%s", progressLine())
+		} else {
+			fmt.Fprintln(w, progressLine())
+		}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				print()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		print()
+		if terminal {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+type phaseTiming struct {
+	name     string
+	duration time.Duration
+}
+
+var (
+	phaseTimings   []phaseTiming
+	phaseTimingsMu sync.Mutex
+)
+
+// 同じ名前の区間は足し合わせる (ルートごとの walk など)
+func startPhase(name string) func() {
+	start := time.Now()
+	return func() {
+		d := time.Since(start)
+
+		phaseTimingsMu.Lock()
+		defer phaseTimingsMu.Unlock()
+		for i := range phaseTimings {
+			if phaseTimings[i].name == name {
+				phaseTimings[i].duration += d
+				return
+			}
+		}
+		phaseTimings = append(phaseTimings, phaseTiming{name: name, duration: d})
+	}
+}
+
+func printTimings(w io.Writer, total time.Duration) {
+	phaseTimingsMu.Lock()
+	defer phaseTimingsMu.Unlock()
+
+	fmt.Fprintln(w, "timings:")
+	for _, t := range phaseTimings {
+		fmt.Fprintf(w, "  %-8s %v\n", t.name, t.duration.Round(time.Microsecond))
+	}
+	fmt.Fprintf(w, "  %-8s %v\n", "total", total.Round(time.Microsecond))
+	phaseTimings = nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/alecthomas/kingpin"
+	"go.uber.org/zap"
+)
+
+var transitiveReduction = kingpin.Flag("transitive-reduction", "remove edges implied by longer paths (A->C when A->B->C exists) before rendering").Bool()
+
+// 到達可能性を変えない範囲で、より長い経路があるエッジを取り除く。
+// 1 本ずつ、それまでに取り除いた結果の上で判定するので、循環があっても到達可能性は保たれる
+func reduceTransitiveEdges() {
+	succ := map[string]map[string]bool{}
+	for _, edge := range edges {
+		if succ[edge.Src] == nil {
+			succ[edge.Src] = map[string]bool{}
+		}
+		succ[edge.Src][edge.Dst] = true
+	}
+
+	// skip のエッジを使わずに from から to へ行けるか
+	reachable := func(from string, to string, skip Edge) bool {
+		visited := map[string]bool{from: true}
+		stack := []string{from}
+		for len(stack) > 0 {
+			id := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if id == to {
+				return true
+			}
+			for next := range succ[id] {
+				if (id == skip.Src && next == skip.Dst) || visited[next] {
+					continue
+				}
+				visited[next] = true
+				stack = append(stack, next)
+			}
+		}
+		return false
+	}
+
+	var kept []Edge
+	for _, edge := range edges {
+		if !succ[edge.Src][edge.Dst] {
+			continue // 種類違いの同じエッジがすでに取り除かれている
+		}
+		implied := false
+		for via := range succ[edge.Src] {
+			if via != edge.Dst && reachable(via, edge.Dst, Edge{Src: edge.Src, Dst: edge.Dst}) {
+				implied = true
+				break
+			}
+		}
+		if implied {
+			delete(succ[edge.Src], edge.Dst)
+			continue
+		}
+		kept = append(kept, edge)
+	}
+
+	zap.S().Debugf("transitive reduction removed %d edges", len(edges)-len(kept))
+	edges = kept
+}
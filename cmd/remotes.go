@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var failOnSkew = remotesCmd.Flag("fail-on-skew", "exit with an error if the same remote base is pinned to different refs").Bool()
+
+type RemoteReference = graph.RemoteReference
+
+var remoteReferences = []RemoteReference{}
+
+func printRemoteReport(w io.Writer) error {
+	bySource := map[string]map[string][]string{}
+	for _, r := range remoteReferences {
+		if bySource[r.Source] == nil {
+			bySource[r.Source] = map[string][]string{}
+		}
+		bySource[r.Source][r.Ref] = append(bySource[r.Source][r.Ref], r.Kustomization)
+	}
+
+	var sources []string
+	for source := range bySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	fmt.Fprintf(w, "%d remote references to %d remote bases\n\n", len(remoteReferences), len(sources))
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REMOTE\tREF\tKUSTOMIZATIONS")
+	var skewed []string
+	for _, source := range sources {
+		refs := bySource[source]
+		var names []string
+		for ref := range refs {
+			names = append(names, ref)
+		}
+		sort.Strings(names)
+		if len(names) > 1 {
+			skewed = append(skewed, source)
+		}
+		for _, ref := range names {
+			users := refs[ref]
+			sort.Strings(users)
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", source, ref, strings.Join(users, ", "))
+		}
+	}
+	tw.Flush()
+
+	if len(skewed) == 0 {
+		fmt.Fprintln(w, "\nno version skew")
+		return nil
+	}
+
+	fmt.Fprintf(w, "\n%d remote bases are pinned to different refs:\n", len(skewed))
+	for _, source := range skewed {
+		var parts []string
+		for ref, users := range bySource[source] {
+			parts = append(parts, fmt.Sprintf("%s (%d)", ref, len(users)))
+		}
+		sort.Strings(parts)
+		fmt.Fprintf(w, "  %s: %s\n", source, strings.Join(parts, " vs "))
+	}
+
+	if *failOnSkew {
+		return fmt.Errorf("%d remote bases are pinned to different refs", len(skewed))
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package main
+
+const (
+	replacementEdgeAttrs       = `color=darkorchid, style=dotted, arrowhead=vee`
+	inlineReplacementNodeAttrs = `shape=note, style="filled,dashed", fillcolor=lavender, fontsize=10`
+)
+
+// 詳細モードで表示する、ファイルに切り出されていない replacement (ID → ラベル)
+var inlineReplacements = map[string]string{}
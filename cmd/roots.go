@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/alecthomas/kingpin"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var (
+	defaultExcludes = kingpin.Flag("default-excludes", "skip hidden directories, node_modules and vendor while discovering kustomizations (--no-default-excludes to disable)").Default("true").Bool()
+	useGitignore    = kingpin.Flag("gitignore", "skip directories ignored by .gitignore files while discovering kustomizations").Bool()
+)
+
+func scanOptions() graph.Options {
+	return graph.Options{
+		TopDir:          *topDir,
+		Jobs:            *jobs,
+		Detail:          *detail,
+		Reproducible:    *reproducible,
+		BuildStats:      *buildStats,
+		ResolveSymlinks: *resolveSymlinks,
+		DefaultExcludes: *defaultExcludes,
+		Gitignore:       *useGitignore,
+		RootOverlap:     *rootOverlap,
+		Extractors:      extractors(),
+		OnEvent:         emitEvent,
+		Phase:           startPhase,
+	}
+}
+
+func scanRoots(fs filesys.FileSystem, roots []string) error {
+	opts := scanOptions()
+	if *scanShard != "" {
+		i, n, err := parseShard(*scanShard)
+		if err != nil {
+			return err
+		}
+		opts.ShardIndex, opts.ShardCount = i, n
+	}
+
+	g, _, err := graph.Scan(fs, roots, opts)
+	if err != nil {
+		return err
+	}
+	useGraph(g)
+	return nil
+}
+
+// 出力はこのパッケージの変数を見るので、走査結果をそのまま入れる
+func useGraph(g *graph.Graph) {
+	rootDir = g.RootDir
+	nodes = g.Nodes
+	edges = g.Edges
+	helmCharts = g.HelmCharts
+	missingFiles = g.MissingFiles
+	notFoundRefs = g.NotFound
+	remoteReferences = g.RemoteReferences
+	edgeEntries = g.EdgeEntries
+	inlineReplacements = g.InlineReplacements
+	referencedPaths = g.ReferencedPaths
+	parsedDirs = g.Dirs
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// GitHub の code scanning が読める SARIF 2.1.0 の必要な部分だけ
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	Level        string             `json:"level"`
+	Message      sarifMessage       `json:"message"`
+	Locations    []sarifLocation    `json:"locations"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind string `json:"kind"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func sarifLevel(severity Severity) string {
+	if severity == SeverityInfo {
+		return "note"
+	}
+	return string(severity)
+}
+
+func printSARIF(w io.Writer, findings []LintFinding) error {
+	driver := sarifDriver{Name: "kustomize-graphing", InformationURI: "https://github.com/ks-yuzu/kustomize-graphing"}
+
+	var rules []string
+	for rule := range lintRuleDescriptions {
+		rules = append(rules, string(rule))
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		driver.Rules = append(driver.Rules, sarifRule{ID: rule, ShortDescription: sarifMessage{Text: lintRuleDescriptions[LintRule(rule)]}})
+	}
+
+	results := []sarifResult{}
+	for _, f := range findings {
+		result := sarifResult{
+			RuleID:  f.Rule,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				// topDir をリポジトリのルートとみなす
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(f.File), URIBaseID: "%SRCROOT%"},
+				Region:           sarifRegion{StartLine: f.Line},
+			}}},
+		}
+		if f.Suppressed {
+			// --baseline にあるもの。code scanning では抑制済みとして扱われる
+			result.Suppressions = []sarifSuppression{{Kind: "external"}}
+		}
+		results = append(results, result)
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(log)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"golang.org/x/exp/slices"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var (
+	scanCmd   = withTopDirArg(kingpin.Command("scan", "scan the tree and write a snapshot for 'merge'"))
+	scanShard = scanCmd.Flag("shard", "scan only the top-level directories assigned to shard i of n, as 'i/n' (1-based)").String()
+
+	mergeCmd       = kingpin.Command("merge", "merge snapshots written by 'scan' and render them like 'graph'")
+	mergeSnapshots = mergeCmd.Arg("snapshots", "snapshot files").Required().ExistingFiles()
+)
+
+const snapshotVersion = 1
+
+type NotFoundRef = graph.NotFoundRef
+
+// 参照先が見つからなかったもの。シャード間で同じ base を読んだときに重複して数えないように持っておく
+var notFoundRefs = map[NotFoundRef]bool{}
+
+// scan が書き出す、走査結果そのもの。doc と example タグは model docs で使う
+type Snapshot struct {
+	Version          int               `json:"version" doc:"snapshot format version; merge rejects other versions" example:"1"`
+	Shard            string            `json:"shard" doc:"--shard the snapshot was scanned with; empty when not sharded" example:"1/4"`
+	RootDir          DirNode           `json:"rootDir" doc:"directory tree of the scanned kustomizations, used for clusters"`
+	Nodes            []*Node           `json:"nodes" doc:"kustomizations, sorted by path"`
+	Edges            []Edge            `json:"edges" doc:"references between kustomizations, files and helm charts, sorted"`
+	HelmCharts       []HelmChartNode   `json:"helmCharts" doc:"helm charts used by helmCharts or helmChartInflationGenerator, sorted"`
+	MissingFiles     []string          `json:"missingFiles" doc:"file nodes (detail mode) that do not exist, relative to topDir" example:"[\"apps/web/base/app.conf\"]"`
+	NotFound         []NotFoundRef     `json:"notFound" doc:"references whose target does not exist"`
+	RemoteReferences []RemoteReference `json:"remoteReferences" doc:"references to remote bases, which are not fetched"`
+	EdgeEntries      []EdgeEntry       `json:"edgeEntries" doc:"kustomization.yaml entries that created each edge, used by --edge-labels"`
+
+	InlineReplacements map[string]string `json:"inlineReplacements" doc:"labels of replacements written inline in detail mode, by file node ID" example:"{\"apps/web#replacements[0]\": \"replacements[0] (inline)\\nConfigMap/env.data.NAME\\n→ 1 target\"}"`
+}
+
+// edgeEntries の 1 件分 (キーの Edge のままでは JSON にできないので)
+type EdgeEntry struct {
+	Src     string   `json:"src" doc:"source of the edge" example:"apps/web/overlays/prod"`
+	Dst     string   `json:"dst" doc:"destination of the edge" example:"apps/web/base"`
+	Entries []string `json:"entries" doc:"entries as written in the kustomization.yaml: field, index and value" example:"[\"resources[0]: ../../base\"]"`
+}
+
+func parseShard(shard string) (int, int, error) {
+	invalid := fmt.Errorf("invalid shard %q (expected 'i/n' with 1 <= i <= n)", shard)
+
+	is, ns, ok := strings.Cut(shard, "/")
+	if !ok {
+		return 0, 0, invalid
+	}
+	i, err := strconv.Atoi(is)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	n, err := strconv.Atoi(ns)
+	if err != nil || i < 1 || i > n {
+		return 0, 0, invalid
+	}
+	return i, n, nil
+}
+
+func printSnapshot(w io.Writer) error {
+	snapshot := Snapshot{
+		Version:          snapshotVersion,
+		Shard:            *scanShard,
+		RootDir:          rootDir,
+		Edges:            edges,
+		HelmCharts:       helmCharts,
+		RemoteReferences: remoteReferences,
+
+		InlineReplacements: inlineReplacements,
+	}
+	for _, node := range nodes {
+		snapshot.Nodes = append(snapshot.Nodes, node)
+	}
+	sort.Slice(snapshot.Nodes, func(i, j int) bool {
+		return snapshot.Nodes[i].Path < snapshot.Nodes[j].Path
+	})
+	for file := range missingFiles {
+		snapshot.MissingFiles = append(snapshot.MissingFiles, file)
+	}
+	sort.Strings(snapshot.MissingFiles)
+	for ref := range notFoundRefs {
+		snapshot.NotFound = append(snapshot.NotFound, ref)
+	}
+	sort.Slice(snapshot.NotFound, func(i, j int) bool {
+		a, b := snapshot.NotFound[i], snapshot.NotFound[j]
+		return a.Kustomization+"\x00"+a.Field+"\x00"+a.Path < b.Kustomization+"\x00"+b.Field+"\x00"+b.Path
+	})
+	sort.SliceStable(snapshot.RemoteReferences, func(i, j int) bool {
+		a, b := snapshot.RemoteReferences[i], snapshot.RemoteReferences[j]
+		if a.Kustomization != b.Kustomization {
+			return a.Kustomization < b.Kustomization
+		}
+		return a.Raw < b.Raw
+	})
+	for edge, entries := range edgeEntries {
+		snapshot.EdgeEntries = append(snapshot.EdgeEntries, EdgeEntry{Src: edge.Src, Dst: edge.Dst, Entries: entries})
+	}
+	sort.Slice(snapshot.EdgeEntries, func(i, j int) bool {
+		a, b := snapshot.EdgeEntries[i], snapshot.EdgeEntries[j]
+		if a.Src != b.Src {
+			return a.Src < b.Src
+		}
+		return a.Dst < b.Dst
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(snapshot)
+}
+
+func mergeDirNode(dst *DirNode, src *DirNode) {
+	for _, k := range src.Kustomizations {
+		if !slices.Contains(dst.Kustomizations, k) {
+			dst.Kustomizations = append(dst.Kustomizations, k)
+		}
+	}
+	for _, f := range src.Files {
+		if !slices.Contains(dst.Files, f) {
+			dst.Files = append(dst.Files, f)
+		}
+	}
+	for name, child := range src.Children {
+		if _, ok := dst.Children[name]; !ok {
+			dst.Children[name] = &DirNode{Children: map[string]*DirNode{}}
+		}
+		mergeDirNode(dst.Children[name], child)
+	}
+}
+
+// シャードをまたいで読まれた base は複数のスナップショットに入っているので、重複を除いて足し合わせる
+func loadSnapshots(paths []string) error {
+	knownEdges := map[Edge]bool{}
+	for _, edge := range edges {
+		knownEdges[edge] = true
+	}
+	knownRemotes := map[RemoteReference]bool{}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if snapshot.Version != snapshotVersion {
+			return fmt.Errorf("%s: unsupported snapshot version %d", path, snapshot.Version)
+		}
+
+		mergeDirNode(&rootDir, &snapshot.RootDir)
+		for _, node := range snapshot.Nodes {
+			nodes[node.Path] = node
+		}
+		for _, edge := range snapshot.Edges {
+			if !knownEdges[edge] {
+				knownEdges[edge] = true
+				edges = append(edges, edge)
+			}
+		}
+		for _, chart := range snapshot.HelmCharts {
+			if !slices.Contains(helmCharts, chart) {
+				helmCharts = append(helmCharts, chart)
+			}
+		}
+		for _, file := range snapshot.MissingFiles {
+			missingFiles[file] = true
+		}
+		for _, ref := range snapshot.NotFound {
+			notFoundRefs[ref] = true
+		}
+		for _, r := range snapshot.RemoteReferences {
+			if !knownRemotes[r] {
+				knownRemotes[r] = true
+				remoteReferences = append(remoteReferences, r)
+			}
+		}
+		for id, label := range snapshot.InlineReplacements {
+			inlineReplacements[id] = label
+		}
+		for _, e := range snapshot.EdgeEntries {
+			key := Edge{Src: e.Src, Dst: e.Dst}
+			for _, entry := range e.Entries {
+				edgeEntries[key] = graph.AppendEdgeEntry(edgeEntries[key], entry)
+			}
+		}
+	}
+	sortGraph()
+	return nil
+}
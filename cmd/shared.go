@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var sharedEmphasis = kingpin.Flag("shared-emphasis", "make kustomizations referenced by several others stand out in DOT output: 'size' scales them, 'badge' adds a 'shared by N' line").Default("none").Enum("none", "size", "badge")
+
+// printGraph のたびに数え直す (mergeEquivalentLeaves などで edges が変わるため)
+var sharedReferrers = map[string]int{}
+
+func countSharedReferrers() {
+	referrers := map[string]map[string]bool{}
+	for _, edge := range edges {
+		if !isKustomizationEdge(edge) {
+			continue
+		}
+		if referrers[edge.Dst] == nil {
+			referrers[edge.Dst] = map[string]bool{}
+		}
+		referrers[edge.Dst][edge.Src] = true
+	}
+
+	sharedReferrers = map[string]int{}
+	for path, srcs := range referrers {
+		if len(srcs) > 1 {
+			sharedReferrers[path] = len(srcs)
+		}
+	}
+}
+
+func sharedLabel(path string) string {
+	if n := sharedReferrers[path]; n > 0 && *sharedEmphasis == "badge" {
+		return fmt.Sprintf("\n◆ shared by %d", n)
+	}
+	return ""
+}
+
+// 参照元が倍になるごとに文字を半分ずつ大きくする
+func sharedAttrs(path string) string {
+	if n := sharedReferrers[path]; n > 0 && *sharedEmphasis == "size" {
+		return fmt.Sprintf(", fontsize=%.0f", 14*(1+0.5*math.Log2(float64(n))))
+	}
+	return ""
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/alecthomas/kingpin"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	splitByRoot = kingpin.Flag("split-by-root", "write one graph per entry point (see 'roots') into --output-dir, named after its path").Bool()
+	outputDir   = kingpin.Flag("output-dir", "directory --split-by-root writes into").String()
+)
+
+var outputExtensions = map[string]string{
+	"dot":       ".dot",
+	"tree":      ".txt",
+	"graphml":   ".graphml",
+	"cytoscape": ".json",
+	"csv":       ".csv",
+	"backstage": ".yaml",
+	"plantuml":  ".puml",
+	"d2":        ".d2",
+}
+
+func splitOutputPath(root string) string {
+	ext := outputExtensions[*format]
+	if *render != "" {
+		ext = "." + *render
+	}
+	if root == "." {
+		root = "root"
+	}
+	return filepath.Join(*outputDir, filepath.FromSlash(root)+ext)
+}
+
+// 出力先は root のパスをそのままディレクトリ構造にするので、別の root と衝突しない
+func writeSplitByRoot(print func(w io.Writer) error) error {
+	switch {
+	case *outputDir == "":
+		return fmt.Errorf("--split-by-root requires --output-dir")
+	case *output != "":
+		return fmt.Errorf("--split-by-root writes into --output-dir; --output cannot be used")
+	case *pipeDot != "":
+		return fmt.Errorf("--split-by-root cannot be used with --pipe-dot")
+	case *mergeLeaves:
+		return fmt.Errorf("--split-by-root cannot be used with --merge-leaves")
+	}
+
+	full := saveGraph()
+	defer restoreGraph(full)
+
+	for _, root := range findRoots() {
+		restrictGraph(reachableFrom(root))
+
+		*output = splitOutputPath(root)
+		if err := os.MkdirAll(filepath.Dir(*output), 0o755); err != nil {
+			return err
+		}
+		var err error
+		if useGraphviz() {
+			err = runGraphviz(print)
+		} else {
+			err = writeOutput(print)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", root, err)
+		}
+		zap.S().Debugf("wrote %s", *output)
+
+		restoreGraph(full)
+	}
+	*output = ""
+	return nil
+}
+
+func reachableFrom(root string) map[string]bool {
+	deps := map[string][]string{}
+	for _, edge := range edges {
+		deps[edge.Src] = append(deps[edge.Src], edge.Dst)
+	}
+
+	reached := map[string]bool{root: true}
+	queue := []string{root}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range deps[id] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reached
+}
+
+type savedGraph struct {
+	rootDir           DirNode
+	nodes             map[string]*Node
+	edges             []Edge
+	helmCharts        []HelmChartNode
+	depthPlaceholders map[string]int
+}
+
+func copyDirNode(node *DirNode) DirNode {
+	c := DirNode{
+		Kustomizations: slices.Clone(node.Kustomizations),
+		Files:          slices.Clone(node.Files),
+		Children:       map[string]*DirNode{},
+	}
+	for name, child := range node.Children {
+		childCopy := copyDirNode(child)
+		c.Children[name] = &childCopy
+	}
+	return c
+}
+
+func saveGraph() savedGraph {
+	s := savedGraph{
+		rootDir:           copyDirNode(&rootDir),
+		nodes:             map[string]*Node{},
+		edges:             slices.Clone(edges),
+		helmCharts:        slices.Clone(helmCharts),
+		depthPlaceholders: map[string]int{},
+	}
+	for id, node := range nodes {
+		s.nodes[id] = node
+	}
+	for id, n := range depthPlaceholders {
+		s.depthPlaceholders[id] = n
+	}
+	return s
+}
+
+func restoreGraph(s savedGraph) {
+	rootDir = copyDirNode(&s.rootDir)
+	nodes = map[string]*Node{}
+	for id, node := range s.nodes {
+		nodes[id] = node
+	}
+	edges = slices.Clone(s.edges)
+	helmCharts = slices.Clone(s.helmCharts)
+	depthPlaceholders = map[string]int{}
+	for id, n := range s.depthPlaceholders {
+		depthPlaceholders[id] = n
+	}
+}
+
+// keep にないノードと、それにつながるエッジを除く。
+// 詳細モードのファイルは参照元のクラスタに置かれているので、removeNodes ではなく木を直接たどる
+func restrictGraph(keep map[string]bool) {
+	var restrict func(node *DirNode, dirName string)
+	restrict = func(node *DirNode, dirName string) {
+		var kustomizations, files []string
+		for _, k := range node.Kustomizations {
+			if keep[filepath.Join(dirName, k)] {
+				kustomizations = append(kustomizations, k)
+			}
+		}
+		for _, f := range node.Files {
+			if keep[f] {
+				files = append(files, f)
+			}
+		}
+		node.Kustomizations, node.Files = kustomizations, files
+		for name, child := range node.Children {
+			restrict(child, filepath.Join(dirName, name))
+		}
+	}
+	restrict(&rootDir, "")
+	pruneEmptyDirNodes(&rootDir)
+
+	for id := range nodes {
+		if !keep[id] {
+			delete(nodes, id)
+		}
+	}
+	kept := []Edge{}
+	for _, edge := range edges {
+		if keep[edge.Src] && keep[edge.Dst] {
+			kept = append(kept, edge)
+		}
+	}
+	edges = kept
+
+	var charts []HelmChartNode
+	for _, chart := range helmCharts {
+		if keep[chart.ID()] {
+			charts = append(charts, chart)
+		}
+	}
+	helmCharts = charts
+	for id := range depthPlaceholders {
+		if !keep[id] {
+			delete(depthPlaceholders, id)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var (
+	boundaryDepth   = statsCmd.Flag("boundary-depth", "number of leading path segments that make up a cluster for boundary counts").Default("1").Int()
+	boundaryBudgets = statsCmd.Flag("boundary-budget", "maximum number of edges allowed across a boundary, as 'from->to=N' (repeatable)").Strings()
+	statsJSON       = statsCmd.Flag("json", "print stats as JSON").Bool()
+	statsTop        = statsCmd.Flag("top", "number of entries in the most-referenced / widest fan-out / most complex rankings").Default("5").Int()
+)
+
+type NodeCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+type Metrics = graph.Metrics
+
+type NodeMetrics struct {
+	Path string `json:"path"`
+	Metrics
+}
+
+type BoundaryCount struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Edges int    `json:"edges"`
+}
+
+type Stats struct {
+	Nodes            int           `json:"nodes"`
+	Kustomizations   int           `json:"kustomizations"`
+	Edges            int           `json:"edges"`
+	MaxDepth         int           `json:"maxDepth"`
+	MostReferenced   []NodeCount   `json:"mostReferenced"`
+	WidestFanOut     []NodeCount   `json:"widestFanOut"`
+	BrokenReferences int           `json:"brokenReferences"`
+	BuildFailures    *int          `json:"buildFailures,omitempty"` // --build-stats のときだけ
+	NodeMetrics      []NodeMetrics `json:"nodeMetrics"`             // 複雑な順
+
+	InternalEdges   int             `json:"internalEdges"`
+	CrossingEdges   int             `json:"crossingEdges"`
+	BoundaryEdges   []BoundaryCount `json:"boundaryEdges"`
+	BudgetsExceeded []string        `json:"budgetsExceeded,omitempty"`
+}
+
+func boundaryGroup(path string) string {
+	segments := strings.Split(path, "/")
+	if len(segments) > *boundaryDepth {
+		segments = segments[:*boundaryDepth]
+	}
+	return strings.Join(segments, "/")
+}
+
+func isKustomizationEdge(edge Edge) bool {
+	return edge.Type == EdgeTypeResource || edge.Type == EdgeTypeComponent
+}
+
+// 件数の多い順に上位 n 件
+func topNodeCounts(counts map[string]int, n int) []NodeCount {
+	ranking := []NodeCount{}
+	for path, count := range counts {
+		ranking = append(ranking, NodeCount{Path: path, Count: count})
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Count != ranking[j].Count {
+			return ranking[i].Count > ranking[j].Count
+		}
+		return ranking[i].Path < ranking[j].Path
+	})
+	if len(ranking) > n {
+		ranking = ranking[:n]
+	}
+	return ranking
+}
+
+// 依存の連鎖の最大の長さ (エッジ数)。循環はその手前で打ち切る
+func maxDependencyDepth(deps map[string][]string) int {
+	depths := map[string]int{}
+	onPath := map[string]bool{}
+	var depth func(id string) int
+	depth = func(id string) int {
+		if d, ok := depths[id]; ok {
+			return d
+		}
+		onPath[id] = true
+		d := 0
+		for _, next := range deps[id] {
+			if onPath[next] {
+				continue
+			}
+			if n := depth(next) + 1; n > d {
+				d = n
+			}
+		}
+		delete(onPath, id)
+		depths[id] = d
+		return d
+	}
+
+	max := 0
+	for id := range deps {
+		if d := depth(id); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+func collectStats() (*Stats, error) {
+	if *boundaryDepth < 1 {
+		return nil, fmt.Errorf("--boundary-depth must be at least 1")
+	}
+	stats := &Stats{
+		Nodes:            len(flatNodes()),
+		Kustomizations:   len(nodes),
+		Edges:            len(edges),
+		BrokenReferences: len(notFoundRefs),
+		BoundaryEdges:    []BoundaryCount{},
+	}
+
+	deps := map[string][]string{}
+	inDegree := map[string]int{}
+	outDegree := map[string]int{}
+	for _, edge := range edges {
+		if !isKustomizationEdge(edge) {
+			continue
+		}
+		deps[edge.Src] = append(deps[edge.Src], edge.Dst)
+		inDegree[edge.Dst]++
+		outDegree[edge.Src]++
+	}
+	stats.MaxDepth = maxDependencyDepth(deps)
+	if *buildStats {
+		failures := countBuildFailures()
+		stats.BuildFailures = &failures
+	}
+	stats.MostReferenced = topNodeCounts(inDegree, *statsTop)
+	stats.WidestFanOut = topNodeCounts(outDegree, *statsTop)
+
+	stats.NodeMetrics = []NodeMetrics{}
+	for path, node := range nodes {
+		stats.NodeMetrics = append(stats.NodeMetrics, NodeMetrics{Path: path, Metrics: node.Metrics})
+	}
+	sort.Slice(stats.NodeMetrics, func(i, j int) bool {
+		a, b := stats.NodeMetrics[i], stats.NodeMetrics[j]
+		if a.TotalEntries != b.TotalEntries {
+			return a.TotalEntries > b.TotalEntries
+		}
+		return a.Path < b.Path
+	})
+
+	counts := map[[2]string]int{}
+	for _, edge := range edges {
+		if !isKustomizationEdge(edge) {
+			continue
+		}
+		from, to := boundaryGroup(edge.Src), boundaryGroup(edge.Dst)
+		if from == to {
+			stats.InternalEdges++
+			continue
+		}
+		stats.CrossingEdges++
+		counts[[2]string{from, to}]++
+	}
+	for key, n := range counts {
+		stats.BoundaryEdges = append(stats.BoundaryEdges, BoundaryCount{From: key[0], To: key[1], Edges: n})
+	}
+	sort.Slice(stats.BoundaryEdges, func(i, j int) bool {
+		a, b := stats.BoundaryEdges[i], stats.BoundaryEdges[j]
+		if a.Edges != b.Edges {
+			return a.Edges > b.Edges
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+
+	for _, budget := range *boundaryBudgets {
+		from, to, max, err := parseBoundaryBudget(budget)
+		if err != nil {
+			return nil, err
+		}
+		if n := counts[[2]string{from, to}]; n > max {
+			stats.BudgetsExceeded = append(stats.BudgetsExceeded, fmt.Sprintf("%s->%s: %d edges (budget %d)", from, to, n, max))
+		}
+	}
+
+	return stats, nil
+}
+
+func parseBoundaryBudget(budget string) (string, string, int, error) {
+	invalid := fmt.Errorf("invalid boundary budget %q (expected 'from->to=N')", budget)
+
+	i := strings.LastIndex(budget, "=")
+	if i < 0 {
+		return "", "", 0, invalid
+	}
+	max, err := strconv.Atoi(budget[i+1:])
+	if err != nil {
+		return "", "", 0, invalid
+	}
+	from, to, ok := strings.Cut(budget[:i], "->")
+	if !ok {
+		return "", "", 0, invalid
+	}
+	return strings.TrimSpace(from), strings.TrimSpace(to), max, nil
+}
+
+func printStats(w io.Writer) error {
+	stats, err := collectStats()
+	if err != nil {
+		return err
+	}
+
+	if *statsJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(stats); err != nil {
+			return err
+		}
+	} else {
+		total := stats.InternalEdges + stats.CrossingEdges
+		ratio := 0.0
+		if total > 0 {
+			ratio = 100 * float64(stats.CrossingEdges) / float64(total)
+		}
+		fmt.Fprintf(w, "nodes:                  %d (%d kustomizations)\n", stats.Nodes, stats.Kustomizations)
+		fmt.Fprintf(w, "edges:                  %d\n", stats.Edges)
+		fmt.Fprintf(w, "max depth:              %d\n", stats.MaxDepth)
+		fmt.Fprintf(w, "broken references:      %d\n", stats.BrokenReferences)
+		if stats.BuildFailures != nil {
+			fmt.Fprintf(w, "build failures:         %d\n", *stats.BuildFailures)
+		}
+		fmt.Fprintf(w, "edges inside clusters:  %d\n", stats.InternalEdges)
+		fmt.Fprintf(w, "edges across clusters:  %d (%.1f%%)\n", stats.CrossingEdges, ratio)
+
+		for _, ranking := range []struct {
+			title  string
+			counts []NodeCount
+		}{
+			{"most referenced", stats.MostReferenced},
+			{"widest fan-out", stats.WidestFanOut},
+		} {
+			if len(ranking.counts) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "\n%s:\n", ranking.title)
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			for _, c := range ranking.counts {
+				fmt.Fprintf(tw, "  %s\t%d\n", c.Path, c.Count)
+			}
+			tw.Flush()
+		}
+
+		if len(stats.NodeMetrics) > 0 {
+			fmt.Fprintln(w, "\nmost complex:")
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "  PATH\tENTRIES\tLINES\tBYTES\tPATCH LINES")
+			for i, m := range stats.NodeMetrics {
+				if i >= *statsTop {
+					break
+				}
+				fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%d\n", m.Path, m.TotalEntries, m.Lines, m.FileSize, m.PatchLines)
+			}
+			tw.Flush()
+		}
+
+		if len(stats.BoundaryEdges) > 0 {
+			fmt.Fprintf(w, "\ncluster boundaries (depth %d):\n", *boundaryDepth)
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "  FROM\tTO\tEDGES")
+			for _, b := range stats.BoundaryEdges {
+				fmt.Fprintf(tw, "  %s\t%s\t%d\n", b.From, b.To, b.Edges)
+			}
+			tw.Flush()
+		}
+		for _, exceeded := range stats.BudgetsExceeded {
+			fmt.Fprintf(w, "\nbudget exceeded: %s\n", exceeded)
+		}
+	}
+
+	if len(stats.BudgetsExceeded) > 0 {
+		return fmt.Errorf("%d boundary budgets exceeded", len(stats.BudgetsExceeded))
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/alecthomas/kingpin"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+var resolveSymlinks = kingpin.Flag("resolve-symlinks", "follow symlinked directories while discovering and identify nodes by their real path (--no-resolve-symlinks to disable)").Default("true").Bool()
+
+// --input でアーカイブから読むときは、ディスク上のリンクを解決しない
+var onDisk = true
+
+// シンボリックリンクを解決した絶対パス。存在しないパスは、存在する親までを解決する
+func canonicalPath(path string) string {
+	return graph.CanonicalPath(path, *resolveSymlinks && onDisk)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+var tooltips = kingpin.Flag("tooltips", "give kustomization nodes in DOT output a tooltip with their path, resource count, build result and findings (shown on hover in SVG)").Bool()
+
+// printGraph のたびに集め直す
+var nodeFindings = map[string][]Finding{}
+
+func collectNodeFindings() {
+	nodeFindings = map[string][]Finding{}
+	for _, f := range collectFindings() {
+		nodeFindings[f.Kustomization] = append(nodeFindings[f.Kustomization], f)
+	}
+}
+
+func hasNodeTooltip(path string) bool {
+	return *tooltips && nodes[path] != nil
+}
+
+func nodeTooltipAttrs(path string) string {
+	if !hasNodeTooltip(path) {
+		return ""
+	}
+	node := nodes[path]
+
+	lines := []string{path, node.Kind}
+	if node.Resources == 1 {
+		lines = append(lines, "resources: 1 entry")
+	} else {
+		lines = append(lines, fmt.Sprintf("resources: %d entries", node.Resources))
+	}
+	// ビルドの失敗は findings に含まれる
+	if node.Build != nil && node.Build.Error == "" {
+		lines = append(lines, fmt.Sprintf("build: %d rendered resources", node.Build.Resources))
+	}
+	for _, f := range nodeFindings[path] {
+		line := fmt.Sprintf("[%s] %s: %s", f.Severity, f.Field, f.Message)
+		if f.Suppressed {
+			line += " (baseline)"
+		}
+		lines = append(lines, line)
+	}
+	if note, ok := notes[path]; ok && *notesStyle == "tooltip" {
+		lines = append(lines, "", note)
+	}
+	return fmt.Sprintf(`, tooltip="%s"`, escapeDOT(strings.Join(lines, "\n"), `\n`))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// go mod graph を木にしたような表示。共有されている base には印をつけ、2 回目以降は展開しない
+func printTree(w io.Writer) error {
+	children := map[string][]Edge{}
+	inDegree := map[string]int{}
+	for _, edge := range edges {
+		children[edge.Src] = append(children[edge.Src], edge)
+		inDegree[edge.Dst]++
+	}
+
+	var roots []string
+	for _, node := range flatNodes() {
+		if inDegree[node.ID] == 0 {
+			roots = append(roots, node.ID)
+		}
+	}
+
+	labels := map[string]string{}
+	for _, chart := range helmCharts {
+		labels[chart.ID()] = fmt.Sprintf("%s@%s (%s)", chart.Name, chart.Version, chart.Repo)
+	}
+	for id := range depthPlaceholders {
+		labels[id] = depthPlaceholderLabel(id)
+	}
+
+	expanded := map[string]bool{}
+	elided := false
+	var walk func(id string, edgeType EdgeType, prefix string, last bool, top bool, ancestors map[string]bool)
+	walk = func(id string, edgeType EdgeType, prefix string, last bool, top bool, ancestors map[string]bool) {
+		line := id
+		if label, ok := labels[id]; ok {
+			line = label
+		}
+		if edgeType != "" && edgeType != EdgeTypeResource {
+			line += fmt.Sprintf(" [%s]", edgeType)
+		}
+		if inDegree[id] > 1 {
+			line += fmt.Sprintf(" (shared by %d)", inDegree[id])
+		}
+
+		branch, childPrefix := "", ""
+		if !top {
+			if last {
+				branch, childPrefix = "└── ", prefix+"    "
+			} else {
+				branch, childPrefix = "├── ", prefix+"│   "
+			}
+		}
+
+		switch {
+		case ancestors[id]:
+			fmt.Fprintf(w, "%s%s%s (cycle)\n", prefix, branch, line)
+			return
+		case expanded[id] && len(children[id]) > 0:
+			fmt.Fprintf(w, "%s%s%s (*)\n", prefix, branch, line)
+			elided = true
+			return
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, line)
+		expanded[id] = true
+
+		ancestors[id] = true
+		kids := children[id]
+		sort.SliceStable(kids, func(i, j int) bool { return kids[i].Dst < kids[j].Dst })
+		for i, edge := range kids {
+			walk(edge.Dst, edge.Type, childPrefix, i == len(kids)-1, false, ancestors)
+		}
+		delete(ancestors, id)
+	}
+
+	for _, root := range roots {
+		walk(root, "", "", true, true, map[string]bool{})
+	}
+	// 循環だけでできていて根がない部分
+	for _, node := range flatNodes() {
+		if !expanded[node.ID] {
+			walk(node.ID, "", "", true, true, map[string]bool{})
+		}
+	}
+
+	if elided {
+		fmt.Fprintln(w, "\n(*) already shown above")
+	}
+	return nil
+}
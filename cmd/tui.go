@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const tuiHelp = "↑/↓ move  →/enter expand  ← collapse  r referrers  d dependencies  backspace back  e edit  q quit"
+
+type tuiView struct {
+	reverse  bool     // true なら参照元をたどる
+	roots    []string // この表示の起点
+	expanded map[string]bool
+	cursor   int
+}
+
+type tuiRow struct {
+	key   string // 同じノードが複数箇所に出るので、根からの経路で区別する
+	id    string
+	depth int
+	cycle bool
+}
+
+type tuiModel struct {
+	deps     map[string][]string
+	referrer map[string][]string
+	labels   map[string]string
+
+	view     tuiView
+	history  []tuiView
+	expanded map[string]bool
+	rows     []tuiRow
+	cursor   int
+	offset   int
+	height   int
+	message  string
+}
+
+type editorFinishedMsg struct{ err error }
+
+func runTUI() error {
+	m := newTUIModel()
+	if len(m.rows) == 0 {
+		return fmt.Errorf("no kustomizations found in %s", *topDir)
+	}
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+func newTUIModel() *tuiModel {
+	m := &tuiModel{
+		deps:     map[string][]string{},
+		referrer: map[string][]string{},
+		labels:   map[string]string{},
+		expanded: map[string]bool{},
+		height:   20,
+	}
+	for _, edge := range edges {
+		m.deps[edge.Src] = append(m.deps[edge.Src], edge.Dst)
+		m.referrer[edge.Dst] = append(m.referrer[edge.Dst], edge.Src)
+	}
+	for _, ids := range []map[string][]string{m.deps, m.referrer} {
+		for _, v := range ids {
+			sort.Strings(v)
+		}
+	}
+	for _, chart := range helmCharts {
+		m.labels[chart.ID()] = fmt.Sprintf("⎈ %s@%s", chart.Name, chart.Version)
+	}
+	for id := range depthPlaceholders {
+		m.labels[id] = depthPlaceholderLabel(id)
+	}
+
+	var roots []string
+	for _, node := range flatNodes() {
+		if len(m.referrer[node.ID]) == 0 {
+			roots = append(roots, node.ID)
+		}
+	}
+	m.view = tuiView{roots: roots}
+	m.rebuild()
+	return m
+}
+
+func (m *tuiModel) children(id string) []string {
+	if m.view.reverse {
+		return m.referrer[id]
+	}
+	return m.deps[id]
+}
+
+func (m *tuiModel) rebuild() {
+	m.rows = nil
+	var walk func(id string, key string, depth int, ancestors map[string]bool)
+	walk = func(id string, key string, depth int, ancestors map[string]bool) {
+		row := tuiRow{key: key, id: id, depth: depth, cycle: ancestors[id]}
+		m.rows = append(m.rows, row)
+		if row.cycle || !m.expanded[key] {
+			return
+		}
+		ancestors[id] = true
+		for _, child := range m.children(id) {
+			walk(child, key+"\x00"+child, depth+1, ancestors)
+		}
+		delete(ancestors, id)
+	}
+	for _, root := range m.view.roots {
+		walk(root, root, 0, map[string]bool{})
+	}
+
+	if m.cursor >= len(m.rows) {
+		m.cursor = len(m.rows) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
+func (m *tuiModel) jump(reverse bool) {
+	id := m.rows[m.cursor].id
+	saved := m.view
+	saved.expanded, saved.cursor = m.expanded, m.cursor
+	m.history = append(m.history, saved)
+	m.view = tuiView{reverse: reverse, roots: []string{id}}
+	m.expanded = map[string]bool{id: true}
+	m.cursor = 0
+	m.offset = 0
+	m.rebuild()
+}
+
+func (m *tuiModel) back() {
+	if len(m.history) == 0 {
+		return
+	}
+	m.view = m.history[len(m.history)-1]
+	m.history = m.history[:len(m.history)-1]
+	m.expanded, m.cursor = m.view.expanded, m.view.cursor
+	m.offset = 0
+	m.rebuild()
+}
+
+func (m *tuiModel) editCommand() (*exec.Cmd, error) {
+	id := m.rows[m.cursor].id
+	var path string
+	switch {
+	case nodes[id] != nil:
+		path = filepath.Join(*topDir, id, "kustomization.yaml")
+	case strings.HasPrefix(id, "helm:"):
+		return nil, fmt.Errorf("%s is a helm chart", id)
+	default:
+		path = filepath.Join(*topDir, id)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	return exec.Command("sh", "-c", editor+` "$1"`, "sh", path), nil
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height - 3
+		if m.height < 1 {
+			m.height = 1
+		}
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.message = msg.err.Error()
+		}
+	case tea.KeyMsg:
+		m.message = ""
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.rows)-1 {
+				m.cursor++
+			}
+		case "pgup":
+			m.cursor -= m.height
+			if m.cursor < 0 {
+				m.cursor = 0
+			}
+		case "pgdown":
+			m.cursor += m.height
+			if m.cursor > len(m.rows)-1 {
+				m.cursor = len(m.rows) - 1
+			}
+		case "right", "l", "enter":
+			m.expanded[m.rows[m.cursor].key] = true
+			m.rebuild()
+		case "left", "h":
+			row := m.rows[m.cursor]
+			if m.expanded[row.key] {
+				delete(m.expanded, row.key)
+			} else {
+				// 閉じているときは親に移動する
+				for i := m.cursor - 1; i >= 0; i-- {
+					if m.rows[i].depth < row.depth {
+						m.cursor = i
+						break
+					}
+				}
+			}
+			m.rebuild()
+		case "r":
+			m.jump(true)
+		case "d":
+			m.jump(false)
+		case "backspace", "esc":
+			m.back()
+		case "e":
+			cmd, err := m.editCommand()
+			if err != nil {
+				m.message = err.Error()
+				break
+			}
+			return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+				return editorFinishedMsg{err: err}
+			})
+		}
+	}
+
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.height {
+		m.offset = m.cursor - m.height + 1
+	}
+	return m, nil
+}
+
+func (m *tuiModel) View() string {
+	var b strings.Builder
+
+	title := "dependencies"
+	if m.view.reverse {
+		title = "referrers"
+	}
+	if len(m.history) > 0 {
+		title += " of " + m.view.roots[0]
+	}
+	fmt.Fprintf(&b, "kustomize-graphing: %s\n", title)
+
+	for i := m.offset; i < len(m.rows) && i < m.offset+m.height; i++ {
+		row := m.rows[i]
+
+		marker := "  "
+		switch {
+		case row.cycle:
+			marker = "↺ "
+		case len(m.children(row.id)) == 0:
+			marker = "  "
+		case m.expanded[row.key]:
+			marker = "▾ "
+		default:
+			marker = "▸ "
+		}
+
+		label := row.id
+		if l, ok := m.labels[row.id]; ok {
+			label = l
+		}
+		if n := len(m.referrer[row.id]); n > 1 && !m.view.reverse {
+			label += fmt.Sprintf(" (shared by %d)", n)
+		}
+
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "> "
+		}
+		fmt.Fprintf(&b, "%s%s%s%s\n", cursor, strings.Repeat("  ", row.depth), marker, label)
+	}
+
+	if m.message != "" {
+		fmt.Fprintf(&b, "\n%s\n", m.message)
+	} else {
+		fmt.Fprintf(&b, "\n%s\n", tuiHelp)
+	}
+	return b.String()
+}
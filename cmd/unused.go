@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+var lintUnusedFiles = lintCmd.Flag("unused-files", "report YAML files in kustomization directories that no kustomization references (--no-unused-files to disable)").Default("true").Bool()
+
+// フィールドごとに見ずに、kustomization.yaml のスカラー値をすべてパスの候補とみなす
+// (KRM 関数の設定など、Kustomization 型にない書き方の参照も拾える)
+func referencedFiles(data []byte, dir string, referenced map[string]bool) {
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(data, &doc) != nil {
+		return
+	}
+	var walk func(n *yamlv3.Node)
+	walk = func(n *yamlv3.Node) {
+		if n.Kind == yamlv3.ScalarNode && n.Value != "" {
+			referenced[canonicalPath(filepath.Join(dir, n.Value))] = true
+			// files は "[key=]path" 形式
+			if _, v, ok := strings.Cut(n.Value, "="); ok {
+				referenced[canonicalPath(filepath.Join(dir, v))] = true
+			}
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(&doc)
+}
+
+func isKustomizationFileName(name string) bool {
+	return name == "kustomization.yaml" || name == "kustomization.yml" || name == "Kustomization"
+}
+
+// 別のディレクトリの kustomization から参照されていれば使われているものとする
+func findUnusedFiles(fs filesys.FileSystem, dirs []string) []LintFinding {
+	referenced := map[string]bool{}
+	for _, dir := range dirs {
+		if data, err := fs.ReadFile(filepath.Join(dir, "kustomization.yaml")); err == nil {
+			referencedFiles(data, dir, referenced)
+		}
+	}
+
+	var findings []LintFinding
+	for _, dir := range dirs {
+		names, err := fs.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			ext := filepath.Ext(name)
+			if fs.IsDir(path) || strings.HasPrefix(name, ".") || isKustomizationFileName(name) || (ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			if referenced[canonicalPath(path)] {
+				continue
+			}
+			rel, err := relPath(path)
+			if err != nil {
+				continue
+			}
+			dirRel, err := relPath(dir)
+			if err != nil {
+				continue
+			}
+			findings = append(findings, newLintFinding(LintRuleUnusedFile, dirRel, "", rel, name+" is not referenced by any kustomization", rel, 1))
+		}
+	}
+	return findings
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/graph"
+)
+
+const watchDebounce = 300 * time.Millisecond
+
+// 走査中に参照されたパスと読んだディレクトリ (解決済み)。watch モードで変更を拾う対象にする
+var referencedPaths = map[string]bool{}
+var parsedDirs = map[string]bool{}
+
+// 走査のあとに読むファイル (--notes など) も監視する
+func recordReference(path string) {
+	referencedPaths[canonicalPath(path)] = true
+}
+
+func watchAndRun(command string) error {
+	if *output == "" || *output == "-" {
+		return fmt.Errorf("--watch requires --output")
+	}
+	outputPath := canonicalPath(*output)
+	logger := zap.S()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	rebuild := func() {
+		resetGraph()
+		if err := run(command); err != nil {
+			logger.Errorf("failed to regenerate %s: %v", *output, err)
+		} else {
+			logger.Infof("wrote %s", *output)
+		}
+		addWatches(watcher)
+	}
+	rebuild()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if isRelevantChange(event, outputPath) {
+				logger.Debugf("changed: %s (%s)", event.Name, event.Op)
+				debounce = time.After(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Warnf("watch error: %v", err)
+		case <-debounce:
+			debounce = nil
+			rebuild()
+		}
+	}
+}
+
+// fsnotify は再帰的に監視しないので、ディレクトリを個別に登録する
+func addWatches(watcher *fsnotify.Watcher) {
+	dirs := map[string]bool{}
+	for _, root := range graph.NormalizeRoots(*topDir, *roots) {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				dirs[canonicalPath(path)] = true
+			}
+			return nil
+		})
+	}
+	// ルートの外にある base や参照ファイルも監視する
+	for dir := range parsedDirs {
+		dirs[dir] = true
+	}
+	for path := range referencedPaths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirs[path] = true
+		} else {
+			dirs[filepath.Dir(path)] = true
+		}
+	}
+
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			zap.S().Debugf("failed to watch %s: %v", dir, err)
+		}
+	}
+}
+
+func isRelevantChange(event fsnotify.Event, outputPath string) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+
+	path := canonicalPath(event.Name)
+	if path == outputPath {
+		return false
+	}
+	if filepath.Base(path) == "kustomization.yaml" || referencedPaths[path] {
+		return true
+	}
+	if event.Op.Has(fsnotify.Create) {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
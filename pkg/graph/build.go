@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+type BuildResult struct {
+	Resources int    `json:"resources" doc:"number of resources kustomize build renders" example:"12"`
+	Error     string `json:"error,omitempty" doc:"build error; only when the build fails" example:"accumulating resources: ..."`
+}
+
+// Component は単体ではビルドできないので対象外
+func (s *scanner) collectBuildStats() {
+	var paths []string
+	for path, node := range s.g.Nodes {
+		if node.Kind != "Component" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range work {
+				result := buildKustomization(s.fs, filepath.Join(s.opts.TopDir, path))
+				if result.Error != "" {
+					zap.S().Warnf("%s: build failed: %s", path, result.Error)
+				}
+				s.mu.Lock()
+				s.g.Nodes[path].Build = result
+				s.mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		work <- path
+	}
+	close(work)
+	wg.Wait()
+}
+
+func buildKustomization(fs filesys.FileSystem, dir string) *BuildResult {
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resources, err := k.Run(fs, dir)
+	if err != nil {
+		return &BuildResult{Error: err.Error()}
+	}
+	return &BuildResult{Resources: resources.Size()}
+}
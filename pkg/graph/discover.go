@@ -0,0 +1,190 @@
+package graph
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// メモリ上のファイルシステム (--input のアーカイブなど) では、ディスク上のリンクを解決しない
+func isOnDisk(fs filesys.FileSystem) bool {
+	return fs == filesys.MakeFsOnDisk()
+}
+
+var (
+	canonicalPaths   = map[string]string{}
+	canonicalPathsMu sync.Mutex
+)
+
+// 絶対パス。resolveSymlinks ならシンボリックリンクも解決する (存在しないパスは、存在する親までを解決する)
+func CanonicalPath(path string, resolveSymlinks bool) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = filepath.Clean(path)
+	}
+	if !resolveSymlinks {
+		return abs
+	}
+
+	canonicalPathsMu.Lock()
+	defer canonicalPathsMu.Unlock()
+	return evalSymlinks(abs)
+}
+
+func evalSymlinks(abs string) string {
+	if p, ok := canonicalPaths[abs]; ok {
+		return p
+	}
+	p, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		if parent := filepath.Dir(abs); parent != abs {
+			p = filepath.Join(evalSymlinks(parent), filepath.Base(abs))
+		} else {
+			p = abs
+		}
+	}
+	canonicalPaths[abs] = p
+	return p
+}
+
+func (s *scanner) isSymlink(path string) bool {
+	if !isOnDisk(s.fs) {
+		return false
+	}
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// baseDir の下にある kustomization.yaml のあるディレクトリ。Scan と同じ規則 (除外、シンボリックリンク) で探す
+func FindKustomizationDirs(fs filesys.FileSystem, baseDir string, opts Options) []string {
+	return newScanner(fs, opts).findKustomizationDirs(baseDir)
+}
+
+func (s *scanner) findKustomizationDirs(baseDir string) []string {
+	var kustomizationDirs []string
+
+	s.walkDirs(baseDir, s.newDirExcluder(baseDir), func(dir string) {
+		path := filepath.Join(dir, "kustomization.yaml")
+		if !s.fs.Exists(path) || s.fs.IsDir(path) {
+			return
+		}
+		kustomizationDirs = append(kustomizationDirs, dir)
+
+		rel, _ := s.relPath(dir)
+		s.emit(Event{Type: EventDiscover, Path: rel})
+	})
+
+	return kustomizationDirs
+}
+
+// filepath.Walk はシンボリックリンクのディレクトリに入らないので自前でたどる。
+// 解決後のパスで一度だけ訪れるので、リンクでループしていても止まる
+func (s *scanner) walkDirs(baseDir string, excluder *dirExcluder, fn func(dir string)) {
+	visited := map[string]bool{}
+
+	var walk func(dir string)
+	walk = func(dir string) {
+		key := s.canonicalPath(dir)
+		if visited[key] {
+			zap.S().Debugf("%s is already visited as %s", dir, key)
+			return
+		}
+		visited[key] = true
+		fn(dir)
+
+		names, err := s.fs.ReadDir(dir)
+		if err != nil {
+			zap.S().Debugf("%s: %v", dir, err)
+			return
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if !s.fs.IsDir(path) || excluder.excluded(path, name) {
+				continue
+			}
+			if !s.opts.ResolveSymlinks && s.isSymlink(path) {
+				continue
+			}
+			walk(path)
+		}
+	}
+	walk(baseDir)
+}
+
+// 参照されていればたどるので、除外するのは走査で見つける対象からだけ
+var defaultExcludedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+type dirExcluder struct {
+	s        *scanner
+	ignores  map[string]*gitignore.GitIgnore // .gitignore のあるディレクトリごと
+	loaded   map[string]bool
+	baseDirs []string // 走査の起点より上にある .gitignore (リポジトリのルートまで)
+}
+
+func (s *scanner) newDirExcluder(baseDir string) *dirExcluder {
+	e := &dirExcluder{s: s, ignores: map[string]*gitignore.GitIgnore{}, loaded: map[string]bool{}}
+	if !s.opts.Gitignore {
+		return e
+	}
+
+	dir := s.canonicalPath(baseDir)
+	for {
+		e.load(dir)
+		e.baseDirs = append(e.baseDirs, dir)
+		if s.fs.Exists(filepath.Join(dir, ".git")) {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return e
+}
+
+func (e *dirExcluder) load(dir string) {
+	if e.loaded[dir] {
+		return
+	}
+	e.loaded[dir] = true
+
+	data, err := e.s.fs.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	e.ignores[dir] = gitignore.CompileIgnoreLines(strings.Split(string(data), "\n")...)
+}
+
+func (e *dirExcluder) excluded(path string, name string) bool {
+	if e.s.opts.DefaultExcludes && (strings.HasPrefix(name, ".") || defaultExcludedDirs[name]) {
+		return true
+	}
+	if !e.s.opts.Gitignore {
+		return false
+	}
+
+	abs := e.s.canonicalPath(path)
+	// 親ディレクトリの .gitignore は、そのディレクトリに入ったときに読む
+	e.load(filepath.Dir(abs))
+	for dir, ignore := range e.ignores {
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if ignore.MatchesPath(rel) || ignore.MatchesPath(rel+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package graph
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// kustomization.yaml に書かれている順の entry
+func referenceEntries(field string, values []string) []string {
+	var entries []string
+	for i, v := range values {
+		entries = append(entries, fmt.Sprintf("%s[%d]: %s", field, i, v))
+	}
+	return entries
+}
+
+func helmChartReferenceEntries(k *types.Kustomization) []string {
+	var names []string
+	for _, v := range k.HelmCharts {
+		names = append(names, v.Name)
+	}
+	return referenceEntries("helmCharts", names)
+}
+
+func helmInflatorReferenceEntries(k *types.Kustomization) []string {
+	var names []string
+	for _, v := range k.HelmChartInflationGenerator {
+		names = append(names, v.ChartName)
+	}
+	return referenceEntries("helmChartInflationGenerator", names)
+}
+
+func (s *scanner) recordEdgeEntry(edge Edge, entry string) {
+	key := Edge{Src: edge.Src, Dst: edge.Dst}
+
+	s.mu.Lock()
+	s.g.EdgeEntries[key] = AppendEdgeEntry(s.g.EdgeEntries[key], entry)
+	s.mu.Unlock()
+}
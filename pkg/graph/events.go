@@ -0,0 +1,25 @@
+package graph
+
+type EventType string
+
+const (
+	EventDiscover EventType = "discover"
+	EventParse    EventType = "parse"
+	EventEdge     EventType = "edge"
+	EventIssue    EventType = "issue"
+	EventDone     EventType = "done"
+)
+
+// 走査の途中経過。Options.OnEvent に渡す (--events ndjson の 1 行分)。doc と example タグは model docs で使う
+type Event struct {
+	Type     EventType `json:"type" doc:"discover, parse, edge, issue or done" example:"edge"`
+	Time     string    `json:"time,omitempty" doc:"time the event was emitted (RFC 3339); omitted with --reproducible" example:"2024-01-02T03:04:05.123456789Z"`
+	Path     string    `json:"path,omitempty" doc:"directory discovered or parsed, or the kustomization an issue is about" example:"apps/web/base"`
+	Src      string    `json:"src,omitempty" doc:"source of the edge" example:"apps/web/overlays/prod"`
+	Dst      string    `json:"dst,omitempty" doc:"destination of the edge" example:"apps/web/base"`
+	EdgeType EdgeType  `json:"edgeType,omitempty" doc:"type of the edge, as in Edge" example:"resource"`
+	Field    string    `json:"field,omitempty" doc:"field an issue is about" example:"resources"`
+	Message  string    `json:"message,omitempty" doc:"description of an issue" example:"../../base is not found"`
+	Nodes    int       `json:"nodes,omitempty" doc:"number of kustomizations, in the done event" example:"42"`
+	Edges    int       `json:"edges,omitempty" doc:"number of edges, in the done event" example:"57"`
+}
@@ -0,0 +1,39 @@
+package graph
+
+import (
+	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// kustomization.yaml の標準のフィールド以外から参照を見つけるもの。
+// 見つけた参照は resources などと同じように、存在チェックとグラフへの追加が行われる
+type ReferenceExtractor interface {
+	Name() string
+	Extract(fs filesys.FileSystem, dir string, rel string, kustomization *types.Kustomization, data []byte) ([]ExtractedReference, error)
+}
+
+type ExtractedReference struct {
+	Field string `json:"field"`          // 表示やエラーに使う、参照元の場所 (例: "transformers/config.yaml")
+	Path  string `json:"path"`           // kustomization のディレクトリからの相対パス
+	Type  string `json:"type,omitempty"` // ディレクトリを参照するとき、"resource" (既定) か "component"
+}
+
+// 失敗した extractor は警告だけ出して、標準のフィールドから作ったグラフは残す
+func (s *scanner) extractReferences(dir string, rel string, kustomization *types.Kustomization, data []byte) []ExtractedReference {
+	var refs []ExtractedReference
+	for _, e := range s.opts.Extractors {
+		r, err := e.Extract(s.fs, dir, rel, kustomization, data)
+		if err != nil {
+			zap.S().Warnf("extractor %s failed for %s: %v", e.Name(), rel, err)
+			continue
+		}
+		for _, ref := range r {
+			if ref.Field == "" {
+				ref.Field = "extractor"
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// FixKustomization 前のフィールドで判定する必要があるもの
+var DeprecatedFeatures = map[string]string{
+	"bases":                       "resources",
+	"imageTags":                   "images",
+	"patchesJson6902":             "patches",
+	"patchesStrategicMerge":       "patches",
+	"vars":                        "replacements",
+	"helmChartInflationGenerator": "helmCharts",
+	"commonLabels":                "labels",
+	"configMapGenerator.env":      "configMapGenerator.envs",
+	"secretGenerator.env":         "secretGenerator.envs",
+}
+
+var ignoredFeatureFields = map[string]bool{
+	"apiVersion": true,
+	"kind":       true,
+	"metadata":   true,
+}
+
+func DetectFeatures(k *types.Kustomization) []string {
+	set := map[string]bool{}
+
+	// 値が入っているトップレベルのフィールドはすべて機能として扱う
+	data, err := json.Marshal(k)
+	if err == nil {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(data, &fields) == nil {
+			for field := range fields {
+				if !ignoredFeatureFields[field] {
+					set[field] = true
+				}
+			}
+		}
+	}
+
+	if k.Kind == types.ComponentKind {
+		set["kind:Component"] = true
+	}
+	for _, v := range k.Patches {
+		if v.Path != "" {
+			set["patches.path"] = true
+		}
+		if v.Patch != "" {
+			set["patches.inline"] = true
+		}
+		if v.Target != nil {
+			set["patches.target"] = true
+		}
+	}
+	for _, v := range k.Replacements {
+		if v.Path != "" {
+			set["replacements.path"] = true
+		} else {
+			set["replacements.inline"] = true
+		}
+	}
+	addGeneratorFeatures := func(field string, args types.GeneratorArgs) {
+		if len(args.LiteralSources) > 0 {
+			set[field+".literals"] = true
+		}
+		if len(args.FileSources) > 0 {
+			set[field+".files"] = true
+		}
+		if len(args.EnvSources) > 0 {
+			set[field+".envs"] = true
+		}
+		if args.EnvSource != "" {
+			set[field+".env"] = true
+		}
+		if args.Behavior != "" {
+			set[field+".behavior"] = true
+		}
+	}
+	for _, v := range k.ConfigMapGenerator {
+		addGeneratorFeatures("configMapGenerator", v.GeneratorArgs)
+	}
+	for _, v := range k.SecretGenerator {
+		addGeneratorFeatures("secretGenerator", v.GeneratorArgs)
+	}
+
+	var features []string
+	for feature := range set {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return features
+}
+
+func FeatureFingerprint(features []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(features, ",")))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,125 @@
+package graph
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func (s *scanner) traverseGeneratorSources(rel string, dir string, edgeType EdgeType, args types.GeneratorArgs) error {
+	logger := zap.S()
+
+	var sources []string
+	for _, v := range args.FileSources {
+		// files は "[key=]path" 形式
+		if i := strings.Index(v, "="); i >= 0 {
+			v = v[i+1:]
+		}
+		sources = append(sources, v)
+	}
+	sources = append(sources, args.EnvSources...)
+
+	for _, v := range sources {
+		logger.Debugf("  - (source) %s", v)
+		nextPath := filepath.Join(dir, v)
+		s.recordReference(nextPath)
+
+		if !s.fs.Exists(nextPath) {
+			s.warnNotFound(rel, string(edgeType), nextPath)
+		}
+		if s.opts.Detail {
+			if err := s.appendFileReference(rel, edgeType, nextPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *scanner) appendFileReference(rel string, edgeType EdgeType, path string) error {
+	fileRel, err := s.relPath(path)
+	if err != nil {
+		return err
+	}
+	exists := s.fs.Exists(path)
+
+	s.mu.Lock()
+	if !exists {
+		s.g.MissingFiles[fileRel] = true
+	}
+
+	// ファイルは参照元の kustomization と同じクラスタに置く
+	d := s.g.RootDir.Parent(rel)
+	if !slices.Contains(d.Files, fileRel) {
+		d.Files = append(d.Files, fileRel)
+	}
+	s.mu.Unlock()
+
+	s.appendEdge(Edge{Src: rel, Dst: fileRel, Type: edgeType})
+	return nil
+}
+
+func (s *scanner) traverseReplacements(rel string, dir string, replacements []types.ReplacementField) error {
+	logger := zap.S()
+
+	for i, v := range replacements {
+		if v.Path == "" {
+			label := inlineReplacementLabel(i, v.Replacement)
+			logger.Debugf("- (replacement) %s", label)
+			if s.opts.Detail {
+				s.appendInlineReplacement(rel, fmt.Sprintf("%s#replacements[%d]", rel, i), label)
+			}
+			continue
+		}
+
+		logger.Debugf("- (replacement) %s", v.Path)
+		nextPath := filepath.Join(dir, v.Path)
+		s.recordReference(nextPath)
+
+		if !s.fs.Exists(nextPath) {
+			s.warnNotFound(rel, "replacements", nextPath)
+		}
+		if s.opts.Detail {
+			if err := s.appendFileReference(rel, EdgeTypeReplacement, nextPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 例: "replacements[0] (inline)\nConfigMap/env.data.NAME\n→ 2 targets"
+func inlineReplacementLabel(i int, r types.Replacement) string {
+	label := fmt.Sprintf("replacements[%d] (inline)", i)
+	if s := r.Source; s != nil {
+		source := s.Name
+		if s.Kind != "" {
+			source = s.Kind + "/" + source
+		}
+		if s.FieldPath != "" {
+			source += "." + s.FieldPath
+		}
+		label += "\n" + source
+	}
+	if len(r.Targets) == 1 {
+		return label + "\n→ 1 target"
+	}
+	return label + fmt.Sprintf("\n→ %d targets", len(r.Targets))
+}
+
+func (s *scanner) appendInlineReplacement(rel string, id string, label string) {
+	s.mu.Lock()
+	s.g.InlineReplacements[id] = label
+	d := s.g.RootDir.Parent(rel)
+	if !slices.Contains(d.Files, id) {
+		d.Files = append(d.Files, id)
+	}
+	s.mu.Unlock()
+
+	s.appendEdge(Edge{Src: rel, Dst: id, Type: EdgeTypeReplacement})
+}
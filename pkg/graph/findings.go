@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Scan が返す Finding の Rule。cmd の lint も同じ名前を使う
+const (
+	RuleMissingReference = "missing-reference"
+	RuleBuildFailed      = "build-failed"
+)
+
+type Finding struct {
+	Severity      Severity `json:"severity"`
+	Kustomization string   `json:"kustomization"`
+	Field         string   `json:"field"`
+	Rule          string   `json:"rule,omitempty"`
+	Path          string   `json:"path,omitempty"` // 見つからなかった参照先 (topDir からの相対パス)
+	Message       string   `json:"message"`
+	Suppressed    bool     `json:"suppressed,omitempty"` // baseline にあるもの
+}
+
+// ログではなく走査結果から組み立てるので、merge したスナップショットからも同じものが得られる
+func (g *Graph) Findings() []Finding {
+	findings := []Finding{}
+	for ref := range g.NotFound {
+		findings = append(findings, Finding{
+			Severity:      SeverityError,
+			Kustomization: ref.Kustomization,
+			Field:         ref.Field,
+			Path:          ref.Path,
+			Rule:          RuleMissingReference,
+			Message:       fmt.Sprintf("%s is not found", ref.Path),
+		})
+	}
+	for path, node := range g.Nodes {
+		if node.Build != nil && node.Build.Error != "" {
+			findings = append(findings, Finding{
+				Severity:      SeverityError,
+				Kustomization: path,
+				Field:         "build",
+				Rule:          RuleBuildFailed,
+				Message:       node.Build.Error,
+			})
+		}
+	}
+
+	SortFindings(findings)
+	return findings
+}
+
+func SortFindings(findings []Finding) {
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Kustomization != b.Kustomization {
+			return a.Kustomization < b.Kustomization
+		}
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Rule < b.Rule
+	})
+}
@@ -0,0 +1,147 @@
+// Package graph は kustomization.yaml をたどって依存グラフを作る。
+// コマンド (cmd) はこのパッケージで作ったグラフを各形式で出力する
+package graph
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// json, doc, example タグはグラフのモデルの説明 (model docs) にも使う
+type DirNode struct {
+	Kustomizations []string            `json:"kustomizations" doc:"names of the child directories of this directory that contain a kustomization.yaml" example:"[\"base\", \"overlay\"]"`
+	Files          []string            `json:"files" doc:"file nodes shown in this directory's cluster in detail mode (path from topDir)" example:"[\"apps/web/app.conf\"]"`
+	Children       map[string]*DirNode `json:"children" doc:"subdirectories by name; the top directory itself is \".\""`
+}
+
+type Node struct {
+	Path        string            `json:"path" doc:"directory of the kustomization, relative to topDir" example:"apps/web/overlays/prod"`
+	Kind        string            `json:"kind" doc:"kind of the kustomization: \"Kustomization\" (also when omitted in the file) or \"Component\"" example:"Component"`
+	Annotations map[string]string `json:"annotations" doc:"metadata.annotations of the kustomization" example:"{\"owner\": \"team-web\"}"`
+	Features    []string          `json:"features" doc:"kustomize features the kustomization uses (top-level fields plus variants such as patches.inline), sorted" example:"[\"patches.inline\", \"resources\"]"`
+	Fingerprint string            `json:"fingerprint" doc:"hash of features; kustomizations with the same fingerprint use the same set of features" example:"3f2a9c1b7d4e"`
+	Metrics     Metrics           `json:"metrics" doc:"size and complexity of the kustomization.yaml"`
+
+	Namespace  string `json:"namespace" doc:"namespace field of the kustomization" example:"web"`
+	NamePrefix string `json:"namePrefix" doc:"namePrefix field of the kustomization" example:"prod-"`
+	NameSuffix string `json:"nameSuffix" doc:"nameSuffix field of the kustomization" example:"-v2"`
+	Resources  int    `json:"resources" doc:"number of entries in resources (after bases are folded in)" example:"3"`
+
+	Build *BuildResult `json:"build,omitempty" doc:"result of kustomize build; only with --build-stats"`
+}
+
+type Edge struct {
+	Src  string   `json:"src" doc:"path of the referencing kustomization" example:"apps/web/overlays/prod"`
+	Dst  string   `json:"dst" doc:"referenced kustomization, file path or helm chart ID" example:"apps/web/base"`
+	Type EdgeType `json:"type" doc:"how dst is referenced: resource, component, helmChart, configMapGenerator, secretGenerator, replacement or extracted" example:"resource"`
+}
+
+type EdgeType string
+
+const (
+	EdgeTypeResource  EdgeType = "resource"
+	EdgeTypeComponent EdgeType = "component"
+	EdgeTypeHelmChart EdgeType = "helmChart"
+
+	EdgeTypeConfigMapGenerator EdgeType = "configMapGenerator"
+	EdgeTypeSecretGenerator    EdgeType = "secretGenerator"
+	EdgeTypeReplacement        EdgeType = "replacement"
+	EdgeTypeExtracted          EdgeType = "extracted" // ReferenceExtractor で見つけたファイル
+)
+
+type NotFoundRef struct {
+	Kustomization string `json:"kustomization" doc:"path of the referencing kustomization" example:"apps/web/base"`
+	Field         string `json:"field" doc:"field the reference is written in" example:"configMapGenerator"`
+	Path          string `json:"path" doc:"referenced path that does not exist, relative to topDir" example:"apps/web/base/app.conf"`
+}
+
+// Scan の結果。パスはすべて Options.TopDir からの相対パス
+type Graph struct {
+	RootDir    DirNode
+	Nodes      map[string]*Node
+	Edges      []Edge
+	HelmCharts []HelmChartNode
+
+	MissingFiles       map[string]bool      // 詳細モードで見つからなかったファイル
+	NotFound           map[NotFoundRef]bool // 参照先が見つからなかったもの
+	RemoteReferences   []RemoteReference
+	EdgeEntries        map[Edge][]string // 参照元・参照先の組 (Type なし) ごとの、参照を作った entry (例: "resources[1]: ../base")
+	InlineReplacements map[string]string // 詳細モードで表示する、ファイルに切り出されていない replacement (ID → ラベル)
+
+	// watch などで使う、シンボリックリンクを解決した絶対パス
+	Dirs            map[string]bool // 読んだ kustomization のディレクトリ
+	ReferencedPaths map[string]bool // 参照されたパス
+}
+
+func New() *Graph {
+	return &Graph{
+		RootDir:            DirNode{Children: map[string]*DirNode{}},
+		Nodes:              map[string]*Node{},
+		Edges:              []Edge{},
+		HelmCharts:         []HelmChartNode{},
+		MissingFiles:       map[string]bool{},
+		NotFound:           map[NotFoundRef]bool{},
+		RemoteReferences:   []RemoteReference{},
+		EdgeEntries:        map[Edge][]string{},
+		InlineReplacements: map[string]string{},
+		Dirs:               map[string]bool{},
+		ReferencedPaths:    map[string]bool{},
+	}
+}
+
+// path の親ディレクトリのノード。途中のノードがなければ作る
+func (d *DirNode) Parent(path string) *DirNode {
+	parentDirs := strings.Split(filepath.Dir(strings.Trim(path, "/")), "/")
+
+	for _, parentDir := range parentDirs {
+		if _, ok := d.Children[parentDir]; !ok {
+			d.Children[parentDir] = &DirNode{Children: map[string]*DirNode{}}
+		}
+		d = d.Children[parentDir]
+	}
+	return d
+}
+
+func (d *DirNode) Sort() {
+	sort.Strings(d.Kustomizations)
+	sort.Strings(d.Files)
+	for _, child := range d.Children {
+		child.Sort()
+	}
+}
+
+func SortEdges(edges []Edge) {
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].Src != edges[j].Src {
+			return edges[i].Src < edges[j].Src
+		}
+		if edges[i].Dst != edges[j].Dst {
+			return edges[i].Dst < edges[j].Dst
+		}
+		return edges[i].Type < edges[j].Type
+	})
+}
+
+func SortHelmCharts(charts []HelmChartNode) {
+	slices.SortFunc(charts, func(a, b HelmChartNode) bool {
+		return a.ID() < b.ID()
+	})
+}
+
+// 並列に読むと追加順が実行ごとに変わるので、走査後に並べ直す
+func (g *Graph) Sort() {
+	g.RootDir.Sort()
+	SortEdges(g.Edges)
+	SortHelmCharts(g.HelmCharts)
+}
+
+// entries になければ entry を足す
+func AppendEdgeEntry(entries []string, entry string) []string {
+	if slices.Contains(entries, entry) {
+		return entries
+	}
+	return append(entries, entry)
+}
@@ -0,0 +1,33 @@
+package graph
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slices"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+type HelmChartNode struct {
+	Name    string `json:"name" doc:"chart name" example:"postgresql"`
+	Repo    string `json:"repo" doc:"chart repository URL; empty for a local chart" example:"https://charts.bitnami.com/bitnami"`
+	Version string `json:"version" doc:"chart version; empty for the latest" example:"12.1.0"`
+}
+
+func (c HelmChartNode) ID() string {
+	return fmt.Sprintf("helm:%s/%s@%s", c.Repo, c.Name, c.Version)
+}
+
+// helmChartInflationGenerator は FixKustomization で helmCharts に寄せられる
+func (s *scanner) appendHelmChart(src string, chart types.HelmChart, entry string) {
+	node := HelmChartNode{Name: chart.Name, Repo: chart.Repo, Version: chart.Version}
+
+	s.mu.Lock()
+	if !slices.Contains(s.g.HelmCharts, node) {
+		s.g.HelmCharts = append(s.g.HelmCharts, node)
+	}
+	s.mu.Unlock()
+
+	edge := Edge{Src: src, Dst: node.ID(), Type: EdgeTypeHelmChart}
+	s.appendEdge(edge)
+	s.recordEdgeEntry(edge, entry)
+}
@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// kustomization.yaml 1 つ分の大きさ・複雑さ
+type Metrics struct {
+	FileSize     int            `json:"fileSize" doc:"size of the kustomization.yaml in bytes" example:"512"`
+	Lines        int            `json:"lines" doc:"number of lines of the kustomization.yaml" example:"24"`
+	Entries      map[string]int `json:"entries" doc:"number of elements per top-level field, before deprecated fields are folded in" example:"{\"resources\": 3, \"patches\": 1}"`
+	TotalEntries int            `json:"totalEntries" doc:"sum of entries" example:"4"`
+	PatchLines   int            `json:"patchLines" doc:"total lines of inline and file patches" example:"40"`
+}
+
+func countLines(data []byte) int {
+	if len(data) == 0 {
+		return 0
+	}
+	n := strings.Count(string(data), "\n")
+	if data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// FixKustomization で deprecated なフィールドがまとめられる前に数える
+func countEntries(k *types.Kustomization) map[string]int {
+	entries := map[string]int{}
+
+	data, err := json.Marshal(k)
+	if err != nil {
+		return entries
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal(data, &fields) != nil {
+		return entries
+	}
+	for field, value := range fields {
+		if ignoredFeatureFields[field] {
+			continue
+		}
+		switch v := value.(type) {
+		case []interface{}:
+			entries[field] = len(v)
+		case map[string]interface{}:
+			entries[field] = len(v)
+		default:
+			entries[field] = 1
+		}
+	}
+	return entries
+}
+
+func collectMetrics(fs filesys.FileSystem, dir string, data []byte, k *types.Kustomization, entries map[string]int) Metrics {
+	metrics := Metrics{FileSize: len(data), Lines: countLines(data), Entries: entries}
+	for _, n := range entries {
+		metrics.TotalEntries += n
+	}
+
+	for _, v := range k.Patches {
+		if v.Patch != "" {
+			metrics.PatchLines += countLines([]byte(v.Patch))
+		}
+		if v.Path != "" {
+			if patch, err := fs.ReadFile(filepath.Join(dir, v.Path)); err == nil {
+				metrics.PatchLines += countLines(patch)
+			}
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type RemoteReference struct {
+	Kustomization string `json:"kustomization" doc:"path of the referencing kustomization" example:"apps/web/base"`
+	Field         string `json:"field" doc:"field the reference is written in" example:"resources"`
+	Raw           string `json:"raw" doc:"reference as written in the kustomization.yaml" example:"https://github.com/org/repo//deploy?ref=v1.2.0"`
+	Source        string `json:"source" doc:"repository and path without the ref, normalized so that spellings of the same repository compare equal" example:"github.com/org/repo//deploy"`
+	Ref           string `json:"ref" doc:"ref or version query parameter; \"(default branch)\" when omitted" example:"v1.2.0"`
+}
+
+var hostLikePrefix = regexp.MustCompile(`^[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)+/`)
+
+// リモートの取得はしないので、kustomize のリモート指定の書式かどうかだけで判定する
+func IsRemoteReference(v string) bool {
+	return strings.Contains(v, "://") ||
+		strings.HasPrefix(v, "git@") ||
+		strings.HasPrefix(v, "git::") ||
+		strings.Contains(v, "?ref=") ||
+		strings.Contains(v, "?version=") ||
+		hostLikePrefix.MatchString(v)
+}
+
+func parseRemoteReference(v string) (source string, ref string) {
+	source = v
+	if i := strings.Index(v, "?"); i >= 0 {
+		source = v[:i]
+		if query, err := url.ParseQuery(v[i+1:]); err == nil {
+			ref = query.Get("ref")
+			if ref == "" {
+				ref = query.Get("version")
+			}
+		}
+	}
+
+	// 同じリポジトリの書き方の揺れを吸収する
+	source = strings.TrimPrefix(source, "git::")
+	for _, scheme := range []string{"https://", "http://", "ssh://", "file://"} {
+		source = strings.TrimPrefix(source, scheme)
+	}
+	if strings.HasPrefix(source, "git@") {
+		source = strings.Replace(strings.TrimPrefix(source, "git@"), ":", "/", 1)
+	}
+	source = strings.Replace(source, ".git//", "//", 1)
+	source = strings.TrimSuffix(strings.TrimSuffix(source, "/"), ".git")
+
+	if ref == "" {
+		ref = "(default branch)"
+	}
+	return source, ref
+}
+
+func (s *scanner) appendRemoteReference(rel string, field string, v string) {
+	source, ref := parseRemoteReference(v)
+
+	s.mu.Lock()
+	s.g.RemoteReferences = append(s.g.RemoteReferences, RemoteReference{Kustomization: rel, Field: field, Raw: v, Source: source, Ref: ref})
+	s.mu.Unlock()
+}
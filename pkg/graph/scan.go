@@ -0,0 +1,581 @@
+package graph
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/ks-yuzu/kustomize-graphing/pkg/util"
+)
+
+type Options struct {
+	TopDir string // ノードのパスの基準 (空なら ".")
+	Jobs   int    // 並列に読む kustomization の数
+
+	Detail       bool // ファイル単位の参照 (generator のソースなど) もノードにする
+	Reproducible bool // パスの区切りを '/' にし、イベントに実行環境の絶対パスを含めない
+	BuildStats   bool // kustomize build を実行して Node.Build に結果を入れる (遅い)
+
+	ResolveSymlinks bool   // シンボリックリンクを解決したパスでノードを識別する (ディスク上のファイルシステムのときだけ)
+	DefaultExcludes bool   // 隠しディレクトリ、node_modules、vendor を走査で見つける対象から外す
+	Gitignore       bool   // .gitignore で無視されるディレクトリを走査で見つける対象から外す
+	RootOverlap     string // ルートが重なっているとき: "merge" (info ログ), "warn" または "error"
+
+	// 1 始まりの、担当するシャードの番号と数。トップレベルのディレクトリ名のハッシュで割り振る (ShardCount が 0 なら分割しない)
+	ShardIndex int
+	ShardCount int
+
+	Extractors []ReferenceExtractor // 標準のフィールド以外から参照を見つけるもの。ワーカーから並列に呼ばれる
+
+	OnEvent func(e Event)            // ワーカーから並列に呼ばれる
+	Phase   func(name string) func() // walk, parse, build の区間の計測。返す関数で区間を終える
+}
+
+func DefaultOptions() Options {
+	return Options{
+		TopDir:          ".",
+		Jobs:            runtime.NumCPU(),
+		ResolveSymlinks: true,
+		DefaultExcludes: true,
+		RootOverlap:     "merge",
+	}
+}
+
+// 重複を除き、外側のルートから順に並べる。空なら topDir
+func NormalizeRoots(topDir string, dirs []string) []string {
+	if len(dirs) == 0 {
+		return []string{filepath.Clean(topDir)}
+	}
+
+	seen := map[string]bool{}
+	var normalized []string
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			normalized = append(normalized, dir)
+		}
+	}
+
+	// 外側のルートから順に処理することで、結果を引数の順序に依存させない
+	sort.Slice(normalized, func(i, j int) bool {
+		di, dj := strings.Count(normalized[i], string(filepath.Separator)), strings.Count(normalized[j], string(filepath.Separator))
+		if di != dj {
+			return di < dj
+		}
+		return normalized[i] < normalized[j]
+	})
+	return normalized
+}
+
+// roots の下にある kustomization を見つけ、参照をたどってグラフを作る。
+// 参照先が見つからないものなどは、エラーにせず Finding として返す
+func Scan(fs filesys.FileSystem, roots []string, opts Options) (*Graph, []Finding, error) {
+	s := newScanner(fs, opts)
+	if err := s.scanRoots(NormalizeRoots(s.opts.TopDir, roots)); err != nil {
+		return nil, nil, err
+	}
+	if s.opts.BuildStats {
+		stop := s.phase("build")
+		s.collectBuildStats()
+		stop()
+	}
+	return s.g, s.g.Findings(), nil
+}
+
+type scanner struct {
+	fs      filesys.FileSystem
+	opts    Options
+	resolve bool // シンボリックリンクを解決するか
+
+	g  *Graph
+	mu sync.Mutex // 並列に走査するため、g への追加はこのロックを取って行う
+
+	// 以下は traverse のループ (1 つの goroutine) の中でだけ触る
+	parsedDirs   map[string]*parsedDir // 解決済みパスごとに一度だけ読む (共有される base を参照元の数だけ読み直さない)
+	queuedDirs   map[string]bool
+	rootClosures map[string]map[string]bool // ルートごとにたどった kustomization
+	currentRoot  string
+}
+
+type parsedDir struct {
+	rel  string
+	next []string
+}
+
+func newScanner(fs filesys.FileSystem, opts Options) *scanner {
+	if opts.TopDir == "" {
+		opts.TopDir = "."
+	}
+	if opts.Jobs < 1 {
+		opts.Jobs = 1
+	}
+	return &scanner{
+		fs:           fs,
+		opts:         opts,
+		resolve:      opts.ResolveSymlinks && isOnDisk(fs),
+		g:            New(),
+		parsedDirs:   map[string]*parsedDir{},
+		queuedDirs:   map[string]bool{},
+		rootClosures: map[string]map[string]bool{},
+	}
+}
+
+func (s *scanner) canonicalPath(path string) string {
+	if !isOnDisk(s.fs) {
+		// メモリ上のファイルシステムは "/" を起点にする
+		return filepath.Join(filesys.Separator, path)
+	}
+	return CanonicalPath(path, s.resolve)
+}
+
+func (s *scanner) relPath(path string) (string, error) {
+	rel, err := filepath.Rel(s.canonicalPath(s.opts.TopDir), s.canonicalPath(path))
+	if err != nil {
+		return "", err
+	}
+	if s.opts.Reproducible {
+		rel = filepath.ToSlash(rel)
+	}
+	return rel, nil
+}
+
+func (s *scanner) emit(e Event) {
+	if s.opts.OnEvent != nil {
+		s.opts.OnEvent(e)
+	}
+}
+
+func (s *scanner) phase(name string) func() {
+	if s.opts.Phase == nil {
+		return func() {}
+	}
+	return s.opts.Phase(name)
+}
+
+func (s *scanner) scanRoots(roots []string) error {
+	discovered := map[string][]string{}
+
+	for _, root := range roots {
+		s.currentRoot = root
+		s.rootClosures[root] = map[string]bool{}
+
+		stopWalk := s.phase("walk")
+		dirs := s.findKustomizationDirs(root)
+		stopWalk()
+		if s.opts.ShardCount > 0 {
+			dirs = shardDirs(root, dirs, s.opts.ShardIndex, s.opts.ShardCount)
+		}
+		for _, dir := range dirs {
+			rel, err := s.relPath(dir)
+			if err != nil {
+				return err
+			}
+			discovered[root] = append(discovered[root], rel)
+		}
+
+		stopParse := s.phase("parse")
+		err := s.traverse(dirs)
+		stopParse()
+		if err != nil {
+			return err
+		}
+	}
+	s.g.Sort()
+
+	return s.reportRootOverlaps(roots, discovered)
+}
+
+// トップレベルのディレクトリ名のハッシュで割り振る。ディレクトリが増減しても他の割り当ては変わらない
+func shardDirs(root string, dirs []string, i int, n int) []string {
+	var assigned []string
+	for _, dir := range dirs {
+		top := "."
+		if rel, err := filepath.Rel(root, dir); err == nil {
+			top = strings.Split(filepath.ToSlash(rel), "/")[0]
+		}
+		h := fnv.New32a()
+		h.Write([]byte(top))
+		if int(h.Sum32()%uint32(n)) == i-1 {
+			assigned = append(assigned, dir)
+		}
+	}
+	return assigned
+}
+
+// あるルートで見つかった kustomization が、別のルートからたどれる範囲に含まれていれば重複とみなす
+func (s *scanner) reportRootOverlaps(roots []string, discovered map[string][]string) error {
+	logger := zap.S()
+
+	for _, root := range roots {
+		for _, other := range roots {
+			if root == other {
+				continue
+			}
+
+			n := 0
+			for _, rel := range discovered[root] {
+				if s.rootClosures[other][rel] {
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+
+			msg := fmt.Sprintf("root %s overlaps root %s (%d of %d kustomizations are reachable from %s)", root, other, n, len(discovered[root]), other)
+			switch s.opts.RootOverlap {
+			case "error":
+				return fmt.Errorf("%s", msg)
+			case "warn":
+				logger.Warn(msg)
+			default:
+				logger.Info(msg)
+			}
+		}
+	}
+	return nil
+}
+
+type workItem struct {
+	dir      string
+	topLevel bool // 参照先としてたどったものではなく、走査で見つけたもの
+}
+
+type workResult struct {
+	item workItem
+	next []string
+	err  error
+}
+
+// 別のルートで読み済みのディレクトリは、読み直さずに到達範囲だけ反映する。
+// rootClosures はワーカーからは触らず、traverse のループの中でだけ更新する
+func (s *scanner) markReached(key string) {
+	p, ok := s.parsedDirs[key]
+	if !ok || s.rootClosures[s.currentRoot][p.rel] {
+		return
+	}
+	s.rootClosures[s.currentRoot][p.rel] = true
+	for _, next := range p.next {
+		s.markReached(s.canonicalPath(next))
+	}
+}
+
+// 参照先のディレクトリをキューに積みながら、Jobs 個のワーカーで並列に読む
+func (s *scanner) traverse(dirs []string) error {
+	work := make(chan workItem)
+	results := make(chan workResult)
+	for i := 0; i < s.opts.Jobs; i++ {
+		go func() {
+			for item := range work {
+				next, err := s.readDir(item.dir)
+				results <- workResult{item: item, next: next, err: err}
+			}
+		}()
+	}
+
+	var pending []workItem
+	push := func(item workItem) {
+		key := s.canonicalPath(item.dir)
+		if s.queuedDirs[key] {
+			s.markReached(key)
+			return
+		}
+		s.queuedDirs[key] = true
+		pending = append(pending, item)
+	}
+	for _, dir := range dirs {
+		push(workItem{dir: dir, topLevel: true})
+	}
+
+	inflight := 0
+	var firstErr error
+	for len(pending) > 0 || inflight > 0 {
+		var send chan workItem
+		var item workItem
+		if len(pending) > 0 {
+			send = work
+			item = pending[0]
+		}
+
+		select {
+		case send <- item:
+			pending = pending[1:]
+			inflight++
+		case r := <-results:
+			inflight--
+			if r.err != nil {
+				if r.item.topLevel {
+					if firstErr == nil {
+						firstErr = r.err
+					}
+					pending = nil
+					continue
+				}
+				// 参照先の読み込みエラーは従来どおり無視する
+				zap.S().Debugf("%s: %v", r.item.dir, r.err)
+				continue
+			}
+			rel, _ := s.relPath(r.item.dir)
+			s.rootClosures[s.currentRoot][rel] = true
+			s.parsedDirs[s.canonicalPath(r.item.dir)] = &parsedDir{rel: rel, next: r.next}
+			if firstErr == nil {
+				for _, dir := range r.next {
+					push(workItem{dir: dir})
+				}
+			}
+		}
+	}
+	close(work)
+
+	return firstErr
+}
+
+func ReadKustomizationFile(fs filesys.FileSystem, dir string) (*types.Kustomization, []byte, error) {
+	data, err := fs.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var k types.Kustomization
+	if err := k.Unmarshal(data); err != nil {
+		return nil, nil, err
+	}
+
+	return &k, data, nil
+}
+
+func (s *scanner) readDir(dir string) ([]string, error) {
+	logger := zap.S()
+	logger.Debugf("----- %s -----", dir)
+
+	kustomization, data, err := ReadKustomizationFile(s.fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	// FixKustomization で deprecated なフィールドが書き換えられる前に記録する
+	features := DetectFeatures(kustomization)
+	entries := countEntries(kustomization)
+	// bases と helmChartInflationGenerator は後ろに移されるので、書かれていたとおりの entry を先に作っておく
+	resourceEntries := append(referenceEntries("resources", kustomization.Resources), referenceEntries("bases", kustomization.Bases)...)
+	helmChartEntries := append(helmChartReferenceEntries(kustomization), helmInflatorReferenceEntries(kustomization)...)
+	kustomization.FixKustomization()
+	metrics := collectMetrics(s.fs, dir, data, kustomization, entries)
+	// pp.Print(kustomization)
+
+	rel, err := s.relPath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s.emit(Event{Type: EventParse, Path: rel})
+
+	s.appendToDirTree(dir, rel)
+	s.appendNode(rel, kustomization, features, metrics)
+
+	type nextDir struct {
+		path     string
+		edgeType EdgeType
+		entry    string // 参照元の kustomization.yaml での書き方
+	}
+	var nextDirs []nextDir
+
+	for i, v := range kustomization.Resources {
+		logger.Debugf("- (resource) %s", v)
+		nextPath := filepath.Join(dir, v)
+		s.recordReference(nextPath)
+
+		if !s.fs.Exists(nextPath) {
+			if IsRemoteReference(v) {
+				s.appendRemoteReference(rel, "resources", v)
+			} else {
+				s.warnNotFound(rel, "resources", nextPath)
+			}
+		} else if s.fs.IsDir(nextPath) {
+			nextDirs = append(nextDirs, nextDir{path: nextPath, edgeType: EdgeTypeResource, entry: resourceEntries[i]})
+		}
+	}
+	for i, v := range kustomization.Components {
+		logger.Debugf("- (component) %s", v)
+		nextPath := filepath.Join(dir, v)
+		s.recordReference(nextPath)
+
+		if !s.fs.Exists(nextPath) {
+			if IsRemoteReference(v) {
+				s.appendRemoteReference(rel, "components", v)
+			} else {
+				s.warnNotFound(rel, "components", nextPath)
+			}
+		} else if s.fs.IsDir(nextPath) {
+			nextDirs = append(nextDirs, nextDir{path: nextPath, edgeType: EdgeTypeComponent, entry: fmt.Sprintf("components[%d]: %s", i, v)})
+		}
+	}
+	for _, v := range kustomization.ConfigMapGenerator {
+		logger.Debugf("- (configMapGenerator) %s", v.Name)
+		err := s.traverseGeneratorSources(rel, dir, EdgeTypeConfigMapGenerator, v.GeneratorArgs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range kustomization.SecretGenerator {
+		logger.Debugf("- (secretGenerator) %s", v.Name)
+		err := s.traverseGeneratorSources(rel, dir, EdgeTypeSecretGenerator, v.GeneratorArgs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, v := range kustomization.HelmCharts {
+		logger.Debugf("- (helm chart) %s %s %s", v.Repo, v.Name, v.Version)
+		s.appendHelmChart(rel, v, helmChartEntries[i])
+	}
+
+	// 以下はファイル単位なので、いったん表示には使わない。存在チェックのみ
+	// 詳細モードとかあってもいいかも
+	for _, v := range kustomization.Patches {
+		logger.Debugf("- (patch) %s", v.Path)
+		nextPath := filepath.Join(dir, v.Path)
+		s.recordReference(nextPath)
+
+		if !s.fs.Exists(nextPath) {
+			s.warnNotFound(rel, "patches", nextPath)
+		}
+	}
+	if err := s.traverseReplacements(rel, dir, kustomization.Replacements); err != nil {
+		return nil, err
+	}
+	for _, v := range kustomization.Transformers {
+		logger.Debugf("- (transformer) %s", v)
+		nextPath := filepath.Join(dir, v)
+		s.recordReference(nextPath)
+
+		if !s.fs.Exists(nextPath) {
+			s.warnNotFound(rel, "transformers", nextPath)
+		}
+	}
+	for _, v := range kustomization.Configurations {
+		logger.Debugf("- (configuration) %s", v)
+		nextPath := filepath.Join(dir, v)
+		s.recordReference(nextPath)
+
+		if !s.fs.Exists(nextPath) {
+			s.warnNotFound(rel, "configurations", nextPath)
+		}
+	}
+	for _, v := range s.extractReferences(dir, rel, kustomization, data) {
+		logger.Debugf("- (%s) %s", v.Field, v.Path)
+		nextPath := filepath.Join(dir, v.Path)
+		s.recordReference(nextPath)
+
+		switch {
+		case !s.fs.Exists(nextPath) && IsRemoteReference(v.Path):
+			s.appendRemoteReference(rel, v.Field, v.Path)
+		case !s.fs.Exists(nextPath):
+			s.warnNotFound(rel, v.Field, nextPath)
+		case s.fs.IsDir(nextPath):
+			edgeType := EdgeTypeResource
+			if v.Type == "component" {
+				edgeType = EdgeTypeComponent
+			}
+			nextDirs = append(nextDirs, nextDir{path: nextPath, edgeType: edgeType, entry: v.Field + ": " + v.Path})
+		case s.opts.Detail:
+			if err := s.appendFileReference(rel, EdgeTypeExtracted, nextPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var nextPaths []string
+	for _, next := range nextDirs {
+		nextDir, err := s.relPath(next.path)
+		if err != nil {
+			return nil, err
+		}
+		edge := Edge{Src: rel, Dst: nextDir, Type: next.edgeType}
+		s.appendEdge(edge)
+		s.recordEdgeEntry(edge, next.entry)
+		nextPaths = append(nextPaths, next.path)
+	}
+
+	return nextPaths, nil
+}
+
+func (s *scanner) appendEdge(newEdge Edge) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zap.S().Debugf("[edge] \"%s\" -> \"%s\"", newEdge.Src, newEdge.Dst)
+	if !util.Contains(s.g.Edges, newEdge) {
+		s.g.Edges = append(s.g.Edges, newEdge)
+		s.emit(Event{Type: EventEdge, Src: newEdge.Src, Dst: newEdge.Dst, EdgeType: newEdge.Type})
+	}
+}
+
+func (s *scanner) warnNotFound(rel string, field string, path string) {
+	ref := NotFoundRef{Kustomization: rel, Field: field, Path: path}
+	if r, err := s.relPath(path); err == nil {
+		ref.Path = r
+	}
+
+	s.mu.Lock()
+	s.g.NotFound[ref] = true
+	s.mu.Unlock()
+
+	zap.S().Warnf("%s is not found", path)
+	if s.opts.Reproducible {
+		// 実行環境の絶対パスを出力に含めない
+		path = ref.Path
+	}
+	s.emit(Event{Type: EventIssue, Path: rel, Field: field, Message: fmt.Sprintf("%s is not found", path)})
+}
+
+func (s *scanner) appendNode(rel string, kustomization *types.Kustomization, features []string, metrics Metrics) {
+	node := &Node{
+		Path:        rel,
+		Kind:        kustomization.Kind,
+		Features:    features,
+		Fingerprint: FeatureFingerprint(features),
+		Metrics:     metrics,
+		Namespace:   kustomization.Namespace,
+		NamePrefix:  kustomization.NamePrefix,
+		NameSuffix:  kustomization.NameSuffix,
+		Resources:   len(kustomization.Resources),
+	}
+	if kustomization.MetaData != nil {
+		node.Annotations = kustomization.MetaData.Annotations
+	}
+
+	s.mu.Lock()
+	s.g.Nodes[rel] = node
+	s.mu.Unlock()
+}
+
+func (s *scanner) appendToDirTree(dir string, rel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.g.Dirs[s.canonicalPath(dir)] = true
+
+	d := s.g.RootDir.Parent(rel)
+
+	basename := filepath.Base(rel)
+	if !slices.Contains(d.Kustomizations, basename) {
+		d.Kustomizations = append(d.Kustomizations, basename)
+	}
+}
+
+func (s *scanner) recordReference(path string) {
+	key := s.canonicalPath(path)
+
+	s.mu.Lock()
+	s.g.ReferencedPaths[key] = true
+	s.mu.Unlock()
+}